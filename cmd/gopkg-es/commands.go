@@ -0,0 +1,493 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/remy8000/gopkg/elastic"
+	"github.com/remy8000/gopkg/tools"
+)
+
+func runInfo(args []string) error {
+	client, server, err := elastic.ClusterInfo()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("client version: %s\nserver version: %s\n", client, server)
+
+	health, err := elastic.ClusterHealth(nil, timeOut)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("cluster: %s\nstatus: %s\nnodes: %d (data %d)\nshards: %d active, %d unassigned\npending tasks: %d\n",
+		health.ClusterName, colorStatus(health.Status), health.NumberOfNodes, health.NumberOfDataNodes,
+		health.ActiveShards, health.UnassignedShards, health.PendingTasks)
+
+	return nil
+}
+
+func runIndices(args []string) error {
+	flags := flag.NewFlagSet("indices", flag.ExitOnError)
+	verbose := flags.Bool("v", false, "print health, docs count and size")
+	flags.Parse(args)
+
+	if !*verbose {
+		indices, err := elastic.ListIndices(flags.Arg(0), timeOut)
+		if err != nil {
+			return err
+		}
+		for _, index := range indices {
+			fmt.Println(index)
+		}
+		return nil
+	}
+
+	indices, err := elastic.CatIndices(flags.Arg(0), timeOut)
+	if err != nil {
+		return err
+	}
+	for _, index := range indices {
+		fmt.Printf("%s\t%s\t%s\t%d docs\t%d bytes\n", colorStatus(index.Health), index.Status, index.Index, index.DocsCount, index.StoreSizeBytes)
+	}
+
+	return nil
+}
+
+func runGet(args []string) error {
+	flags := flag.NewFlagSet("get", flag.ExitOnError)
+	index := flags.String("index", "", "index")
+	flags.Parse(args)
+
+	if *index == "" || flags.NArg() != 1 {
+		return fmt.Errorf("usage: get -index <index> <id>")
+	}
+
+	doc, err := elastic.GetDocById(*index, flags.Arg(0), nil, timeOut)
+	if err != nil {
+		return err
+	}
+	if len(doc) == 0 {
+		return fmt.Errorf("doc %s not found in %s", flags.Arg(0), *index)
+	}
+
+	return printJSON(doc["source"])
+}
+
+func runSearch(args []string) error {
+	flags := flag.NewFlagSet("search", flag.ExitOnError)
+	index := flags.String("index", "", "comma separated indices")
+	queryPath := flags.String("query", "-", "search body file, - for stdin")
+	size := flags.Int("size", 10, "count of hits")
+	profile := flags.Bool("profile", false, "print time spent per query and shard")
+	flags.Parse(args)
+
+	if *index == "" {
+		return fmt.Errorf("usage: search -index <indices> [-query file|-] [-size n] [-profile]")
+	}
+
+	query, err := readQuery(*queryPath)
+	if err != nil {
+		return err
+	}
+	if _, ok := query["size"]; !ok {
+		query["size"] = *size
+	}
+
+	result, err := elastic.SearchWithOptions(strings.Split(*index, ","), query, elastic.SearchOptions{Profile: *profile}, timeOut)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(tools.CLIprintColored(fmt.Sprintf("%d hits", result.Total), tools.INFO))
+	if result.Partial() {
+		fmt.Println(tools.CLIprintColored(fmt.Sprintf("partial results: %d/%d shards failed", result.Shards.Failed, result.Shards.Total), tools.WARNING))
+		for _, f := range result.Shards.Failures {
+			fmt.Println(tools.CLIprintColored(fmt.Sprintf("  %s[%d]: %s", f.Index, f.Shard, f.Reason), tools.WARNING))
+		}
+		if result.Clusters != nil {
+			for name, c := range result.Clusters.Details {
+				if c.Status != "successful" {
+					fmt.Println(tools.CLIprintColored(fmt.Sprintf("  cluster %s: %s", name, c.Status), tools.WARNING))
+				}
+			}
+		}
+	}
+	for _, hit := range result.Hits {
+		fmt.Println(tools.CLIprintColored(fmt.Sprintf("%s/%s score=%v", hit.Index, hit.Id, hit.Score), tools.CYAN))
+		if err := printJSON(hit.Source); err != nil {
+			return err
+		}
+	}
+
+	for _, shard := range result.Profile {
+		fmt.Println(tools.CLIprintColored(fmt.Sprintf("shard %s", shard.Id), tools.MAGENTA))
+		printQueryProfiles(shard.Queries, 1)
+	}
+
+	return nil
+}
+
+func runCount(args []string) error {
+	flags := flag.NewFlagSet("count", flag.ExitOnError)
+	index := flags.String("index", "", "comma separated indices")
+	queryPath := flags.String("query", "", "search body file, - for stdin (all docs when empty)")
+	flags.Parse(args)
+
+	if *index == "" {
+		return fmt.Errorf("usage: count -index <indices> [-query file|-]")
+	}
+
+	var query map[string]interface{}
+	if *queryPath != "" {
+		var err error
+		if query, err = readQuery(*queryPath); err != nil {
+			return err
+		}
+	}
+
+	count, err := elastic.Count(strings.Split(*index, ","), query, timeOut)
+	if err != nil {
+		return err
+	}
+	fmt.Println(count)
+
+	return nil
+}
+
+func runDeleteByQuery(args []string) error {
+	flags := flag.NewFlagSet("delete-by-query", flag.ExitOnError)
+	index := flags.String("index", "", "index")
+	queryPath := flags.String("query", "", "search body file, - for stdin")
+	dryRun := flags.Bool("dry-run", false, "only count docs that would be deleted")
+	allowMatchAll := flags.Bool("allow-match-all", false, "allow a query matching all docs")
+	maxDocs := flags.Int("max-docs", 0, "delete at most n docs (no limit when 0)")
+	flags.Parse(args)
+
+	if *index == "" || *queryPath == "" {
+		return fmt.Errorf("usage: delete-by-query -index <index> -query file|- [-dry-run] [-allow-match-all] [-max-docs n]")
+	}
+
+	query, err := readQuery(*queryPath)
+	if err != nil {
+		return err
+	}
+
+	var opts []elastic.DeleteByQueryOption
+	if *dryRun {
+		opts = append(opts, elastic.WithDryRun())
+	}
+	if *allowMatchAll {
+		opts = append(opts, elastic.WithAllowMatchAll())
+	}
+	if *maxDocs > 0 {
+		opts = append(opts, elastic.WithMaxDocs(*maxDocs))
+	}
+
+	count, err := elastic.DeleteByQuery(*index, query, timeOut, opts...)
+	if err != nil {
+		return err
+	}
+	if *dryRun {
+		fmt.Println(tools.CLIprintColored(fmt.Sprintf("%d docs would be deleted from %s", count, *index), tools.WARNING))
+		return nil
+	}
+	fmt.Println(tools.CLIprintColored(fmt.Sprintf("%d docs deleted from %s", count, *index), tools.INFO))
+
+	return nil
+}
+
+func runReindex(args []string) error {
+	flags := flag.NewFlagSet("reindex", flag.ExitOnError)
+	source := flags.String("source", "", "source index")
+	dest := flags.String("dest", "", "destination index")
+	queryPath := flags.String("query", "", "search body file, - for stdin (all docs when empty)")
+	flags.Parse(args)
+
+	if *source == "" || *dest == "" {
+		return fmt.Errorf("usage: reindex -source <index> -dest <index> [-query file|-]")
+	}
+
+	var query map[string]interface{}
+	if *queryPath != "" {
+		var err error
+		query, err = readQueryClause(*queryPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	count, err := elastic.Reindex(*source, *dest, query, timeOut)
+	if err != nil {
+		return err
+	}
+	fmt.Println(tools.CLIprintColored(fmt.Sprintf("%d docs copied from %s to %s", count, *source, *dest), tools.INFO))
+
+	return nil
+}
+
+func runExport(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	index := flags.String("index", "", "index")
+	out := flags.String("out", "", "NDJSON output file")
+	gzip := flags.Bool("gzip", false, "gzip the output")
+	meta := flags.String("meta", "", "mappings and settings output file (optional)")
+	queryPath := flags.String("query", "", "search body file, - for stdin (all docs when empty)")
+	flags.Parse(args)
+
+	if *index == "" || *out == "" {
+		return fmt.Errorf("usage: export -index <index> -out <file> [-gzip] [-meta <file>] [-query file|-]")
+	}
+
+	opts := elastic.ExportOptions{Gzip: *gzip, TimeOut: timeOut}
+	if *queryPath != "" {
+		query, err := readQueryClause(*queryPath)
+		if err != nil {
+			return err
+		}
+		opts.Query = query
+	}
+
+	if *meta != "" {
+		if err := elastic.ExportIndexMetaToFile(*meta, *index, timeOut); err != nil {
+			return err
+		}
+	}
+
+	count, err := elastic.ExportIndexToFile(*out, *index, opts)
+	if err != nil {
+		return err
+	}
+	fmt.Println(tools.CLIprintColored(fmt.Sprintf("%d docs exported to %s", count, *out), tools.INFO))
+
+	return nil
+}
+
+func runImport(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	in := flags.String("in", "", "NDJSON input file, gzipped or not")
+	index := flags.String("index", "", "target index (index of each line when empty)")
+	keepIds := flags.Bool("keep-ids", false, "keep docs ids")
+	meta := flags.String("meta", "", "create the target index from this mappings and settings file (requires -index)")
+	flags.Parse(args)
+
+	if *in == "" {
+		return fmt.Errorf("usage: import -in <file> [-index <index>] [-keep-ids] [-meta <file>]")
+	}
+
+	if *meta != "" {
+		if *index == "" {
+			return fmt.Errorf("-meta requires -index")
+		}
+		if err := elastic.CreateIndexFromMetaFile(*meta, *index, timeOut); err != nil {
+			return err
+		}
+	}
+
+	opts := elastic.ImportOptions{
+		Index:   *index,
+		KeepIds: *keepIds,
+		TimeOut: timeOut,
+		Progress: func(imported int) {
+			fmt.Fprintf(os.Stderr, "\r%d docs imported", imported)
+		},
+	}
+
+	result, err := elastic.ImportIndexFromFile(*in, opts)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(tools.CLIprintColored(fmt.Sprintf("%d docs imported", result.Imported), tools.INFO))
+	for _, item := range result.Failed {
+		fmt.Println(tools.CLIprintColored(fmt.Sprintf("%s/%s: %s", item.Index, item.Id, item.Error), tools.ERROR))
+	}
+	if len(result.Failed) > 0 {
+		return fmt.Errorf("%d docs failed", len(result.Failed))
+	}
+
+	return nil
+}
+
+func runAlias(args []string) error {
+	flags := flag.NewFlagSet("alias", flag.ExitOnError)
+	name := flags.String("name", "", "alias")
+	from := flags.String("from", "", "index currently holding the alias (optional)")
+	to := flags.String("to", "", "index to move the alias to")
+	flags.Parse(args)
+
+	if *name == "" || *to == "" {
+		return fmt.Errorf("usage: alias -name <alias> [-from <index>] -to <index>")
+	}
+
+	if err := elastic.SwapAlias(*name, *from, *to, timeOut); err != nil {
+		return err
+	}
+	fmt.Println(tools.CLIprintColored(fmt.Sprintf("alias %s now points to %s", *name, *to), tools.INFO))
+
+	return nil
+}
+
+func runAnalyze(args []string) error {
+	flags := flag.NewFlagSet("analyze", flag.ExitOnError)
+	index := flags.String("index", "", "index (optional for built-in analyzers)")
+	analyzer := flags.String("analyzer", "", "analyzer")
+	field := flags.String("field", "", "use the analyzer of this field")
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		return fmt.Errorf("usage: analyze [-index <index>] [-analyzer <name>|-field <field>] <text>")
+	}
+
+	tokens, err := elastic.Analyze(*index, *analyzer, *field, strings.Join(flags.Args(), " "), timeOut)
+	if err != nil {
+		return err
+	}
+	for _, token := range tokens {
+		fmt.Printf("%3d %s [%d-%d] %s\n", token.Position, tools.CLIprintColored(token.Token, tools.CYAN), token.StartOffset, token.EndOffset, token.Type)
+	}
+
+	return nil
+}
+
+func runExplain(args []string) error {
+	flags := flag.NewFlagSet("explain", flag.ExitOnError)
+	index := flags.String("index", "", "index")
+	queryPath := flags.String("query", "-", "search body file, - for stdin")
+	flags.Parse(args)
+
+	if *index == "" || flags.NArg() != 1 {
+		return fmt.Errorf("usage: explain -index <index> -query file|- <id>")
+	}
+
+	query, err := readQuery(*queryPath)
+	if err != nil {
+		return err
+	}
+
+	matched, explanation, err := elastic.Explain(*index, flags.Arg(0), query, timeOut)
+	if err != nil {
+		return err
+	}
+	if !matched {
+		fmt.Println(tools.CLIprintColored("no match", tools.WARNING))
+	}
+	fmt.Print(explanation)
+
+	return nil
+}
+
+func runValidate(args []string) error {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	index := flags.String("index", "", "comma separated indices")
+	queryPath := flags.String("query", "-", "search body file, - for stdin")
+	flags.Parse(args)
+
+	if *index == "" {
+		return fmt.Errorf("usage: validate -index <indices> -query file|-")
+	}
+
+	query, err := readQuery(*queryPath)
+	if err != nil {
+		return err
+	}
+
+	validation, err := elastic.ValidateQuery(strings.Split(*index, ","), query, timeOut)
+	if err != nil {
+		return err
+	}
+	if validation.Valid {
+		fmt.Println(tools.CLIprintColored("valid", tools.INFO))
+	} else {
+		fmt.Println(tools.CLIprintColored("invalid "+validation.Error, tools.ERROR))
+	}
+	for _, e := range validation.Explanations {
+		if e.Valid {
+			fmt.Printf("%s: %s\n", e.Index, e.Explanation)
+		} else {
+			fmt.Printf("%s: %s\n", e.Index, tools.CLIprintColored(e.Error, tools.ERROR))
+		}
+	}
+
+	return nil
+}
+
+// readQuery reads a JSON search body from a file or stdin ("-")
+func readQuery(path string) (map[string]interface{}, error) {
+	var r io.Reader
+	if path == "-" {
+		r = bufio.NewReader(os.Stdin)
+	} else {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var query map[string]interface{}
+	if err := json.NewDecoder(r).Decode(&query); err != nil {
+		return nil, fmt.Errorf("error parsing query: %s", err)
+	}
+
+	return query, nil
+}
+
+// readQueryClause reads the "query" object of a search body
+// a body without it would select all docs, it is an error
+func readQueryClause(path string) (map[string]interface{}, error) {
+	body, err := readQuery(path)
+	if err != nil {
+		return nil, err
+	}
+
+	query, ok := body["query"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("query file %s has no \"query\" object", path)
+	}
+
+	return query, nil
+}
+
+func printJSON(v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(b))
+	return nil
+}
+
+func printQueryProfiles(profiles []elastic.QueryProfile, depth int) {
+	for _, p := range profiles {
+		fmt.Printf("%s%s %.3fms %s\n", strings.Repeat("  ", depth), p.Type, float64(p.TimeNanos)/1e6, p.Description)
+		printQueryProfiles(p.Children, depth+1)
+	}
+}
+
+func colorStatus(status elastic.HealthStatus) string {
+	switch status {
+	case elastic.StatusGreen:
+		return tools.CLIprintColored(string(status), tools.INFO)
+	case elastic.StatusYellow:
+		return tools.CLIprintColored(string(status), tools.YELLOW)
+	default:
+		return tools.CLIprintColored(string(status), tools.ERROR)
+	}
+}
+
+func sortedCommands() []string {
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadQueryClause(t *testing.T) {
+	files := map[string]struct {
+		body    string
+		wantErr bool
+	}{
+		"query":      {`{"query":{"term":{"a":1}},"size":10}`, false},
+		"no query":   {`{"term":{"a":1}}`, true},
+		"not object": {`{"query":"a:1"}`, true},
+		"invalid":    {`{"query":`, true},
+	}
+
+	dir := t.TempDir()
+	for name, file := range files {
+		path := filepath.Join(dir, name+".json")
+		if err := os.WriteFile(path, []byte(file.body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		query, err := readQueryClause(path)
+		if file.wantErr {
+			if err == nil {
+				t.Errorf("%s: got %v, want an error", name, query)
+			}
+			continue
+		}
+		if err != nil || query["term"] == nil {
+			t.Errorf("%s: got %v, %v", name, query, err)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+// gopkg-es is a command-line tool for operators built on the elastic package
+//
+// usage: gopkg-es [global flags] <command> [flags]
+//
+// connection settings are read from an optional YAML file (-config) and the environment
+// (see elastic.Config), global flags override them
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/remy8000/gopkg/elastic"
+	"github.com/remy8000/gopkg/tools"
+)
+
+// a command runs with its own arguments
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+// request timeout in seconds, set by the global -timeout flag
+var timeOut int
+
+var commands = map[string]command{
+	"info":            {"cluster versions and health", runInfo},
+	"indices":         {"list indices: [-v] [pattern]", runIndices},
+	"get":             {"get a doc: -index <index> <id>", runGet},
+	"search":          {"search: -index <indices> [-query file|-] [-size n] [-profile]", runSearch},
+	"count":           {"count docs: -index <indices> [-query file|-]", runCount},
+	"delete-by-query": {"delete docs: -index <index> -query file|- [-dry-run] [-allow-match-all] [-max-docs n]", runDeleteByQuery},
+	"reindex":         {"copy docs: -source <index> -dest <index> [-query file|-]", runReindex},
+	"export":          {"export docs: -index <index> -out <file> [-gzip] [-meta <file>] [-query file|-]", runExport},
+	"import":          {"import docs: -in <file> [-index <index>] [-keep-ids] [-meta <file>]", runImport},
+	"alias":           {"swap an alias: -name <alias> [-from <index>] -to <index>", runAlias},
+	"analyze":         {"analyze text: [-index <index>] [-analyzer <name>|-field <field>] <text>", runAnalyze},
+	"explain":         {"explain a doc score: -index <index> -query file|- <id>", runExplain},
+	"validate":        {"validate a query: -index <indices> -query file|-", runValidate},
+}
+
+func main() {
+	flags := flag.NewFlagSet("gopkg-es", flag.ExitOnError)
+	configPath := flags.String("config", "", "YAML config file")
+	addresses := flags.String("addresses", "", "comma separated elastic addresses")
+	username := flags.String("username", "", "basic auth username")
+	password := flags.String("password", "", "basic auth password")
+	apiKey := flags.String("api-key", "", "base64 encoded api key")
+	cloudId := flags.String("cloud-id", "", "elastic cloud id")
+	caCert := flags.String("ca-cert", "", "path of a PEM CA certificate")
+	flags.IntVar(&timeOut, "timeout", 30, "request timeout in seconds")
+	flags.Usage = usage
+	flags.Parse(os.Args[1:])
+
+	if flags.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[flags.Arg(0)]
+	if !ok {
+		fmt.Fprintln(os.Stderr, tools.CLIprintColored(fmt.Sprintf("unknown command '%s'", flags.Arg(0)), tools.ERROR))
+		usage()
+		os.Exit(2)
+	}
+
+	cfg, err := elastic.LoadConfig(*configPath)
+	if err != nil {
+		fail(err)
+	}
+	if *addresses != "" {
+		cfg.Addresses = strings.Split(*addresses, ",")
+		cfg.CloudID = ""
+	}
+	if *cloudId != "" {
+		cfg.CloudID = *cloudId
+		cfg.Addresses = nil
+	}
+	if *apiKey != "" {
+		cfg.APIKey = *apiKey
+		cfg.Username, cfg.Password = "", ""
+	}
+	if *username != "" {
+		cfg.Username = *username
+		cfg.APIKey = ""
+	}
+	if *password != "" {
+		cfg.Password = *password
+	}
+	if *caCert != "" {
+		cfg.CACertPath = *caCert
+	}
+
+	if err := elastic.SetupFromConfig(cfg); err != nil {
+		fail(err)
+	}
+
+	if err := cmd.run(flags.Args()[1:]); err != nil {
+		fail(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: gopkg-es [-config file] [-addresses ...] [-username ...] [-password ...] [-api-key ...] [-cloud-id ...] [-ca-cert ...] [-timeout sec] <command> [flags]")
+	fmt.Fprintln(os.Stderr, "\ncommands:")
+	for _, name := range sortedCommands() {
+		fmt.Fprintf(os.Stderr, "  %-16s %s\n", name, commands[name].usage)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, tools.CLIprintColored(err.Error(), tools.ERROR))
+	os.Exit(1)
+}
@@ -1,11 +1,16 @@
 package database
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"log"
+	"net"
 	"os"
+	"strconv"
 	"time"
 
+	mysqlDriver "github.com/go-sql-driver/mysql"
 	mysql "gorm.io/driver/mysql"
 	"gorm.io/gorm"
 	gormLogger "gorm.io/gorm/logger"
@@ -19,13 +24,49 @@ type Config struct {
 	Username     string
 	Password     string
 	LoggerConfig gormLogger.Config
-	TimeOut      int
+	TimeOut      int         // dial timeout, in seconds
+	Migrations   []Migration // see LoadSQLMigrations
+	// versioned migrations run before AutoMigrate, so that a renamed column is renamed before AutoMigrate adds it
+	// AutoMigrateFirst runs AutoMigrate first, e.g. for migrations backfilling tables created by AutoMigrate
+	AutoMigrateFirst bool
+
+	// DSN options
+	ReadTimeOut  int               // in seconds, 0 for none
+	WriteTimeOut int               // in seconds, 0 for none
+	Charset      string            // default utf8mb4
+	Collation    string            // default of the driver
+	Location     string            // time zone of parsed times, e.g. "UTC", default Local
+	TLS          *TLSConfig        // nil for a plain connection
+	Params       map[string]string // other DSN params, or system variables to set
+
+	// pool, migrations only use the connection holding their lock
+	MaxOpenConns    int           // 0 for unlimited
+	MaxIdleConns    int           // 0 for the database/sql default (2)
+	ConnMaxLifetime time.Duration // 0 for no limit
+	ConnMaxIdleTime time.Duration // 0 for the default 5 minutes, negative for no limit
+}
+
+// TLSConfig of the database connection
+type TLSConfig struct {
+	CACertPath         string // PEM, system roots when empty
+	CertPath           string // PEM client certificate (optional)
+	KeyPath            string // PEM client key, with CertPath
+	ServerName         string // default the host
+	InsecureSkipVerify bool
 }
 
+// name of the TLS config registered in the mysql driver
+const tlsConfigName = "gopkg-database"
+
 // FKey is a foreign key descriptor.
 type FKey struct {
-	Model interface{}
-	Args  [4]string
+	Model     interface{} // model of the table holding the column
+	Column    string      // column or field name, e.g. "user_id"
+	RefTable  string      // referenced table, e.g. "users"
+	RefColumn string      // referenced column, default "id"
+	OnDelete  string      // CASCADE, SET NULL, RESTRICT or NO ACTION, default RESTRICT
+	OnUpdate  string      // same as OnDelete
+	Name      string      // constraint name, default fk_<table>_<column>
 }
 
 var db *gorm.DB
@@ -37,10 +78,10 @@ func DB() *gorm.DB {
 
 // Setup database (connection, migrations)
 func Setup(config Config, tables []interface{}, fks ...FKey) error {
-	uri := fmt.Sprintf(
-		"%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local&timeout=%vs",
-		config.Username, config.Password, config.Host, config.Port, config.Database, config.TimeOut,
-	)
+	uri, err := config.dsn()
+	if err != nil {
+		return err
+	}
 
 	newLogger := gormLogger.New(
 		log.New(os.Stdout, "\r\n", log.LstdFlags), // io writer
@@ -48,7 +89,6 @@ func Setup(config Config, tables []interface{}, fks ...FKey) error {
 	)
 
 	// be careful not ':=' but '=' in order to get a global variable
-	var err error
 	db, err = gorm.Open(mysql.Open(uri), &gorm.Config{
 		Logger: newLogger,
 	})
@@ -56,22 +96,143 @@ func Setup(config Config, tables []interface{}, fks ...FKey) error {
 		return err
 	}
 
+	// the outbox table is created on first use of the new connection
+	outboxMu.Lock()
+	outboxMigrated = false
+	outboxMu.Unlock()
+
 	// configuration
 	sqlDB, err := db.DB()
 	if err != nil {
 		return err
 	}
 
-	sqlDB.SetConnMaxIdleTime(time.Minute * 5)
+	connMaxIdleTime := config.ConnMaxIdleTime
+	if connMaxIdleTime == 0 {
+		connMaxIdleTime = time.Minute * 5
+	}
+	sqlDB.SetConnMaxIdleTime(connMaxIdleTime)
+	sqlDB.SetConnMaxLifetime(config.ConnMaxLifetime)
+	sqlDB.SetMaxOpenConns(config.MaxOpenConns)
+	if config.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(config.MaxIdleConns)
+	}
+
+	migrate := func() error {
+		if len(config.Migrations) == 0 {
+			return nil
+		}
+		_, err := MigrateUp(config.Migrations, false)
+		return err
+	}
+
+	if !config.AutoMigrateFirst {
+		if err := migrate(); err != nil {
+			return err
+		}
+	}
 
 	err = db.AutoMigrate(tables...)
 	if err != nil {
 		return err
 	}
 
+	if config.AutoMigrateFirst {
+		if err := migrate(); err != nil {
+			return err
+		}
+	}
+
+	for _, fk := range fks {
+		if err := ensureForeignKey(fk); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+// dsn builds the data source name, values are escaped by the driver
+func (config Config) dsn() (string, error) {
+	c := mysqlDriver.NewConfig()
+	c.User = config.Username
+	c.Passwd = config.Password
+	c.Net = "tcp"
+	c.Addr = net.JoinHostPort(config.Host, strconv.Itoa(config.Port))
+	c.DBName = config.Database
+	c.ParseTime = true
+	c.Timeout = time.Duration(config.TimeOut) * time.Second
+	c.ReadTimeout = time.Duration(config.ReadTimeOut) * time.Second
+	c.WriteTimeout = time.Duration(config.WriteTimeOut) * time.Second
+	c.Collation = config.Collation
+
+	c.Loc = time.Local
+	if config.Location != "" {
+		loc, err := time.LoadLocation(config.Location)
+		if err != nil {
+			return "", fmt.Errorf("database location: %w", err)
+		}
+		c.Loc = loc
+	}
+
+	c.Params = map[string]string{}
+	for k, v := range config.Params {
+		c.Params[k] = v
+	}
+	if _, ok := c.Params["charset"]; !ok {
+		c.Params["charset"] = "utf8mb4"
+	}
+	if config.Charset != "" {
+		c.Params["charset"] = config.Charset
+	}
+
+	if config.TLS != nil {
+		tlsConfig, err := config.TLS.build(config.Host)
+		if err != nil {
+			return "", err
+		}
+		if err := mysqlDriver.RegisterTLSConfig(tlsConfigName, tlsConfig); err != nil {
+			return "", err
+		}
+		c.TLSConfig = tlsConfigName
+	}
+
+	return c.FormatDSN(), nil
+}
+
+// build returns the tls config
+func (t TLSConfig) build(host string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         t.ServerName,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = host
+	}
+
+	if t.CACertPath != "" {
+		pem, err := os.ReadFile(t.CACertPath)
+		if err != nil {
+			return nil, fmt.Errorf("database CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("database CA: no certificate in %s", t.CACertPath)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if t.CertPath != "" || t.KeyPath != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertPath, t.KeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("database client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
 // Close current database connection
 func Close() error {
 	sqlDB, err := db.DB()
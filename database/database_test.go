@@ -0,0 +1,170 @@
+package database
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	mysqlDriver "github.com/go-sql-driver/mysql"
+)
+
+func TestDSN(t *testing.T) {
+	base := Config{Host: "db.local", Port: 3306, Database: "app", Username: "user", Password: "secret"}
+
+	tests := []struct {
+		name    string
+		config  func(c *Config)
+		check   func(c *mysqlDriver.Config) string // returns a failure, "" when ok
+		wantErr bool
+	}{
+		{
+			name:   "password escaping",
+			config: func(c *Config) { c.Password = "p@ss/w:rd?x=1&y" },
+			check: func(c *mysqlDriver.Config) string {
+				if c.Passwd != "p@ss/w:rd?x=1&y" || c.User != "user" || c.Addr != "db.local:3306" || c.DBName != "app" {
+					return "user, password, address or database not kept"
+				}
+				return ""
+			},
+		},
+		{
+			name:   "default charset",
+			config: func(c *Config) {},
+			check: func(c *mysqlDriver.Config) string {
+				if c.Params["charset"] != "utf8mb4" {
+					return "charset " + c.Params["charset"]
+				}
+				return ""
+			},
+		},
+		{
+			name:   "charset from params",
+			config: func(c *Config) { c.Params = map[string]string{"charset": "latin1"} },
+			check: func(c *mysqlDriver.Config) string {
+				if c.Params["charset"] != "latin1" {
+					return "charset " + c.Params["charset"]
+				}
+				return ""
+			},
+		},
+		{
+			name: "charset over params",
+			config: func(c *Config) {
+				c.Charset = "utf8"
+				c.Params = map[string]string{"charset": "latin1"}
+			},
+			check: func(c *mysqlDriver.Config) string {
+				if c.Params["charset"] != "utf8" {
+					return "charset " + c.Params["charset"]
+				}
+				return ""
+			},
+		},
+		{
+			name:   "default location",
+			config: func(c *Config) {},
+			check: func(c *mysqlDriver.Config) string {
+				if c.Loc != time.Local || !c.ParseTime {
+					return "location " + c.Loc.String()
+				}
+				return ""
+			},
+		},
+		{
+			name:   "location",
+			config: func(c *Config) { c.Location = "Europe/Paris" },
+			check: func(c *mysqlDriver.Config) string {
+				if c.Loc.String() != "Europe/Paris" {
+					return "location " + c.Loc.String()
+				}
+				return ""
+			},
+		},
+		{
+			name:    "unknown location",
+			config:  func(c *Config) { c.Location = "Nowhere/City" },
+			wantErr: true,
+		},
+		{
+			name:    "missing CA",
+			config:  func(c *Config) { c.TLS = &TLSConfig{CACertPath: filepath.Join(t.TempDir(), "none.pem")} },
+			wantErr: true,
+		},
+		{
+			name:   "tls",
+			config: func(c *Config) { c.TLS = &TLSConfig{CACertPath: writeCA(t)} },
+			check: func(c *mysqlDriver.Config) string {
+				if c.TLSConfig != tlsConfigName || c.TLS == nil {
+					return "tls config not registered"
+				}
+				if c.TLS.ServerName != "db.local" || c.TLS.RootCAs == nil {
+					return "server name " + c.TLS.ServerName
+				}
+				return ""
+			},
+		},
+	}
+
+	for _, test := range tests {
+		config := base
+		test.config(&config)
+
+		dsn, err := config.dsn()
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: no error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+
+		parsed, err := mysqlDriver.ParseDSN(dsn)
+		if err != nil {
+			t.Errorf("%s: parse %s: %v", test.name, dsn, err)
+			continue
+		}
+		if failure := test.check(parsed); failure != "" {
+			t.Errorf("%s: %s in %s", test.name, failure, dsn)
+		}
+	}
+}
+
+// writeCA writes a self-signed CA certificate and returns its path
+func writeCA(t *testing.T) string {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
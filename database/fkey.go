@@ -0,0 +1,146 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// referential actions allowed in FKey
+var fkActions = map[string]bool{
+	"CASCADE":   true,
+	"SET NULL":  true,
+	"RESTRICT":  true,
+	"NO ACTION": true,
+}
+
+// existingFKey is a foreign key read from information_schema
+type existingFKey struct {
+	ConstraintName       string
+	ReferencedTableName  string
+	ReferencedColumnName string
+	DeleteRule           string
+	UpdateRule           string
+}
+
+// ensureForeignKey creates the foreign key when missing
+// an existing foreign key on the column must match the descriptor
+func ensureForeignKey(fk FKey) error {
+
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(fk.Model); err != nil {
+		return fmt.Errorf("foreign key: %w", err)
+	}
+	table := stmt.Schema.Table
+
+	// CHECKS
+	field := stmt.Schema.LookUpField(fk.Column)
+	if field == nil || field.DBName == "" {
+		return fmt.Errorf("foreign key: no column '%s' in table %s", fk.Column, table)
+	}
+	column := field.DBName
+
+	fk, err := fkDefaults(fk, table, column)
+	if err != nil {
+		return err
+	}
+
+	var existing []existingFKey
+	err = db.Raw(`SELECT rc.constraint_name AS constraint_name, kcu.referenced_table_name AS referenced_table_name,
+		kcu.referenced_column_name AS referenced_column_name, rc.delete_rule AS delete_rule, rc.update_rule AS update_rule
+		FROM information_schema.referential_constraints rc
+		JOIN information_schema.key_column_usage kcu ON kcu.constraint_schema = rc.constraint_schema
+			AND kcu.constraint_name = rc.constraint_name AND kcu.table_name = rc.table_name
+		WHERE rc.constraint_schema = DATABASE() AND rc.table_name = ? AND kcu.column_name = ?`, table, column).
+		Scan(&existing).Error
+	if err != nil {
+		return fmt.Errorf("foreign key %s: %w", fk.Name, err)
+	}
+
+	if len(existing) == 0 {
+		sql := fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s (%s) ON DELETE %s ON UPDATE %s",
+			stmt.Quote(clause.Table{Name: table}), stmt.Quote(fk.Name), stmt.Quote(column),
+			stmt.Quote(clause.Table{Name: fk.RefTable}), stmt.Quote(fk.RefColumn), fk.OnDelete, fk.OnUpdate)
+		if err := db.Exec(sql).Error; err != nil {
+			return fmt.Errorf("foreign key %s: %w", fk.Name, err)
+		}
+		return nil
+	}
+
+	return matchFKey(fk, table, column, existing)
+}
+
+// fkDefaults checks a descriptor and sets its defaults, actions are normalized
+func fkDefaults(fk FKey, table, column string) (FKey, error) {
+	if fk.RefTable == "" {
+		return fk, fmt.Errorf("foreign key on %s.%s: no referenced table", table, column)
+	}
+	if fk.RefColumn == "" {
+		fk.RefColumn = "id"
+	}
+	if fk.Name == "" {
+		fk.Name = fmt.Sprintf("fk_%s_%s", table, column)
+	}
+
+	var err error
+	fk.OnDelete, err = fkAction(fk.OnDelete)
+	if err != nil {
+		return fk, fmt.Errorf("foreign key %s: ON DELETE %w", fk.Name, err)
+	}
+	fk.OnUpdate, err = fkAction(fk.OnUpdate)
+	if err != nil {
+		return fk, fmt.Errorf("foreign key %s: ON UPDATE %w", fk.Name, err)
+	}
+
+	return fk, nil
+}
+
+// matchFKey checks that one of the existing constraints of the column matches the descriptor
+func matchFKey(fk FKey, table, column string, existing []existingFKey) error {
+	var mismatches []string
+	for _, e := range existing {
+		var diffs []string
+		if e.ReferencedTableName != fk.RefTable || e.ReferencedColumnName != fk.RefColumn {
+			diffs = append(diffs, fmt.Sprintf("references %s(%s), expected %s(%s)",
+				e.ReferencedTableName, e.ReferencedColumnName, fk.RefTable, fk.RefColumn))
+		}
+		if !sameFkAction(e.DeleteRule, fk.OnDelete) {
+			diffs = append(diffs, fmt.Sprintf("ON DELETE %s, expected %s", e.DeleteRule, fk.OnDelete))
+		}
+		if !sameFkAction(e.UpdateRule, fk.OnUpdate) {
+			diffs = append(diffs, fmt.Sprintf("ON UPDATE %s, expected %s", e.UpdateRule, fk.OnUpdate))
+		}
+		if len(diffs) == 0 {
+			return nil
+		}
+		mismatches = append(mismatches, fmt.Sprintf("%s: %s", e.ConstraintName, strings.Join(diffs, ", ")))
+	}
+
+	return fmt.Errorf("foreign key %s on %s.%s does not match existing constraint %s",
+		fk.Name, table, column, strings.Join(mismatches, "; "))
+}
+
+// fkAction normalizes a referential action, RESTRICT when empty
+func fkAction(action string) (string, error) {
+	action = strings.ToUpper(strings.Join(strings.Fields(action), " "))
+	if action == "" {
+		return "RESTRICT", nil
+	}
+	if !fkActions[action] {
+		return "", fmt.Errorf("unknown action '%s'", action)
+	}
+	return action, nil
+}
+
+// sameFkAction compares referential actions, RESTRICT and NO ACTION are the same in InnoDB
+func sameFkAction(a, b string) bool {
+	norm := func(s string) string {
+		if s == "NO ACTION" {
+			return "RESTRICT"
+		}
+		return s
+	}
+	return norm(strings.ToUpper(a)) == norm(strings.ToUpper(b))
+}
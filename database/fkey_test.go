@@ -0,0 +1,69 @@
+package database
+
+import "testing"
+
+func TestFkAction(t *testing.T) {
+	tests := []struct {
+		action  string
+		want    string
+		wantErr bool
+	}{
+		{"", "RESTRICT", false},
+		{"cascade", "CASCADE", false},
+		{"  set   null ", "SET NULL", false},
+		{"No Action", "NO ACTION", false},
+		{"DROP", "", true},
+	}
+
+	for _, test := range tests {
+		got, err := fkAction(test.action)
+		if (err != nil) != test.wantErr || got != test.want {
+			t.Errorf("%q: got %q, %v", test.action, got, err)
+		}
+	}
+}
+
+func TestFkDefaults(t *testing.T) {
+	fk, err := fkDefaults(FKey{RefTable: "users", OnDelete: "cascade"}, "posts", "user_id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fk.Name != "fk_posts_user_id" || fk.RefColumn != "id" || fk.OnDelete != "CASCADE" || fk.OnUpdate != "RESTRICT" {
+		t.Errorf("got %+v", fk)
+	}
+
+	if _, err := fkDefaults(FKey{}, "posts", "user_id"); err == nil {
+		t.Error("no error without referenced table")
+	}
+	if _, err := fkDefaults(FKey{RefTable: "users", OnUpdate: "nullify"}, "posts", "user_id"); err == nil {
+		t.Error("no error for an unknown action")
+	}
+}
+
+func TestMatchFKey(t *testing.T) {
+	fk, err := fkDefaults(FKey{RefTable: "users", OnDelete: "CASCADE"}, "posts", "user_id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	matching := existingFKey{ConstraintName: "fk_posts_user_id", ReferencedTableName: "users", ReferencedColumnName: "id", DeleteRule: "CASCADE", UpdateRule: "RESTRICT"}
+
+	tests := []struct {
+		name     string
+		existing []existingFKey
+		wantErr  bool
+	}{
+		{"same", []existingFKey{matching}, false},
+		{"no action is restrict", []existingFKey{func() existingFKey { e := matching; e.UpdateRule = "NO ACTION"; return e }()}, false},
+		{"other name", []existingFKey{func() existingFKey { e := matching; e.ConstraintName = "posts_ibfk_1"; return e }()}, false},
+		{"other table", []existingFKey{func() existingFKey { e := matching; e.ReferencedTableName = "accounts"; return e }()}, true},
+		{"other action", []existingFKey{func() existingFKey { e := matching; e.DeleteRule = "SET NULL"; return e }()}, true},
+		{"one of several", []existingFKey{func() existingFKey { e := matching; e.DeleteRule = "SET NULL"; return e }(), matching}, false},
+	}
+
+	for _, test := range tests {
+		err := matchFKey(fk, "posts", "user_id", test.existing)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: got %v", test.name, err)
+		}
+	}
+}
@@ -0,0 +1,531 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migration is a versioned schema change, applied in Version order
+// changes are either Go funcs or SQL (several statements separated by ';')
+// SQL scripts cannot change the DELIMITER nor create procedures, functions, triggers or events with a BEGIN ... END body,
+// use a Go func for those
+// each migration runs in a transaction, but note that MySQL commits DDL statements implicitly
+type Migration struct {
+	Version int64 // e.g. 20261019120000
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+	UpSQL   string
+	DownSQL string
+}
+
+// SchemaMigration records an applied migration
+type SchemaMigration struct {
+	Version   int64 `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+// TableName of the applied migrations
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// MigrationState is the status of a migration
+type MigrationState struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+	Unknown   bool // applied but not in the given migrations
+}
+
+// name of the MySQL lock held while migrating
+const migrationLock = "schema_migrations"
+
+// wait for the lock of another instance, in seconds
+var migrationLockTimeOut = 60
+
+// files of SQL migrations: <version>_<name>.up.sql and <version>_<name>.down.sql
+var migrationFile = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// LoadSQLMigrations reads migrations from the .sql files of a directory, e.g. of an embed.FS
+func LoadSQLMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	var migrations []Migration
+
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return migrations, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		match := migrationFile.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return migrations, fmt.Errorf("migration %s: %w", entry.Name(), err)
+		}
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return migrations, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return migrations, fmt.Errorf("migration %d has two names: %s and %s", version, m.Name, match[2])
+		}
+		if _, err := splitSQL(string(content)); err != nil {
+			return migrations, fmt.Errorf("migration %s: %w", entry.Name(), err)
+		}
+		if match[3] == "up" {
+			m.UpSQL = string(content)
+		} else {
+			m.DownSQL = string(content)
+		}
+	}
+
+	for _, m := range byVersion {
+		if m.UpSQL == "" {
+			return migrations, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// MigrateUp applies the pending migrations, return the applied ones
+// with dryRun, nothing is applied nor locked and the pending ones are returned
+func MigrateUp(migrations []Migration, dryRun bool) ([]Migration, error) {
+	var done []Migration
+
+	sorted, err := sortMigrations(migrations)
+	if err != nil {
+		return done, err
+	}
+
+	err = withMigrationLock(dryRun, func(ldb *gorm.DB) error {
+		if !dryRun {
+			if err := migrateSchemaMigrations(ldb); err != nil {
+				return err
+			}
+		}
+		applied, err := appliedMigrations(ldb)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range sorted {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			if !dryRun {
+				if err := runMigration(ldb, m, true); err != nil {
+					return err
+				}
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+
+	return done, err
+}
+
+// MigrateDown rolls back the last steps applied migrations, return the rolled back ones
+// with dryRun, nothing is rolled back nor locked and the ones that would be are returned
+func MigrateDown(migrations []Migration, steps int, dryRun bool) ([]Migration, error) {
+	var done []Migration
+
+	sorted, err := sortMigrations(migrations)
+	if err != nil {
+		return done, err
+	}
+	byVersion := make(map[int64]Migration)
+	for _, m := range sorted {
+		byVersion[m.Version] = m
+	}
+
+	if steps <= 0 {
+		steps = 1
+	}
+
+	err = withMigrationLock(dryRun, func(ldb *gorm.DB) error {
+		if !ldb.Migrator().HasTable(&SchemaMigration{}) {
+			return nil
+		}
+
+		var applied []SchemaMigration
+		err := ldb.Order("version DESC").Limit(steps).Find(&applied).Error
+		if err != nil {
+			return err
+		}
+
+		for _, a := range applied {
+			m, ok := byVersion[a.Version]
+			if !ok {
+				return fmt.Errorf("migration %d_%s is applied but unknown", a.Version, a.Name)
+			}
+			if m.Down == nil && m.DownSQL == "" {
+				return fmt.Errorf("migration %d_%s has no down", m.Version, m.Name)
+			}
+			if !dryRun {
+				if err := runMigration(ldb, m, false); err != nil {
+					return err
+				}
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+
+	return done, err
+}
+
+// MigrationStatus returns the state of migrations, known and unknown ones, by version
+func MigrationStatus(migrations []Migration) ([]MigrationState, error) {
+	var states []MigrationState
+
+	sorted, err := sortMigrations(migrations)
+	if err != nil {
+		return states, err
+	}
+	if db == nil {
+		return states, fmt.Errorf("database is not set up")
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return states, err
+	}
+
+	for _, m := range sorted {
+		state := MigrationState{Version: m.Version, Name: m.Name}
+		if a, ok := applied[m.Version]; ok {
+			state.Applied, state.AppliedAt = true, a.AppliedAt
+			delete(applied, m.Version)
+		}
+		states = append(states, state)
+	}
+	for _, a := range applied {
+		states = append(states, MigrationState{Version: a.Version, Name: a.Name, Applied: true, AppliedAt: a.AppliedAt, Unknown: true})
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Version < states[j].Version })
+
+	return states, nil
+}
+
+// RunMigrationCommand runs a migration command from command line arguments, to be wired in an app:
+// up [-dry-run], down [-dry-run] [steps, default 1], status
+// results are written to w, e.g. os.Stdout
+func RunMigrationCommand(w io.Writer, migrations []Migration, args []string) error {
+	usage := fmt.Errorf("usage: up [-dry-run] | down [-dry-run] [steps] | status")
+	if len(args) == 0 {
+		return usage
+	}
+
+	dryRun := false
+	steps := 1
+	for _, arg := range args[1:] {
+		if arg == "-dry-run" || arg == "--dry-run" {
+			dryRun = true
+			continue
+		}
+		n, err := strconv.Atoi(arg)
+		if err != nil || n <= 0 || args[0] != "down" {
+			return usage
+		}
+		steps = n
+	}
+
+	verb := "applied"
+	if dryRun {
+		verb = "would apply"
+	}
+
+	switch args[0] {
+	case "up":
+		done, err := MigrateUp(migrations, dryRun)
+		for _, m := range done {
+			fmt.Fprintf(w, "%s %d_%s\n", verb, m.Version, m.Name)
+		}
+		if err == nil && len(done) == 0 {
+			fmt.Fprintln(w, "no pending migration")
+		}
+		return err
+
+	case "down":
+		if dryRun {
+			verb = "would roll back"
+		} else {
+			verb = "rolled back"
+		}
+		done, err := MigrateDown(migrations, steps, dryRun)
+		for _, m := range done {
+			fmt.Fprintf(w, "%s %d_%s\n", verb, m.Version, m.Name)
+		}
+		return err
+
+	case "status":
+		states, err := MigrationStatus(migrations)
+		if err != nil {
+			return err
+		}
+		for _, s := range states {
+			status := "pending"
+			if s.Applied {
+				status = "applied " + s.AppliedAt.Format(time.RFC3339)
+			}
+			if s.Unknown {
+				status += " (unknown)"
+			}
+			fmt.Fprintf(w, "%d_%s\t%s\n", s.Version, s.Name, status)
+		}
+		return nil
+	}
+
+	return usage
+}
+
+// sortMigrations checks and sorts migrations by version
+func sortMigrations(migrations []Migration) ([]Migration, error) {
+	sorted := append([]Migration(nil), migrations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+
+	for i, m := range sorted {
+		if m.Version <= 0 {
+			return sorted, fmt.Errorf("migration %s: version must be positive", m.Name)
+		}
+		if i > 0 && sorted[i-1].Version == m.Version {
+			return sorted, fmt.Errorf("migration version %d is duplicated", m.Version)
+		}
+		if m.Up == nil && m.UpSQL == "" {
+			return sorted, fmt.Errorf("migration %d_%s has no up", m.Version, m.Name)
+		}
+		for _, script := range []string{m.UpSQL, m.DownSQL} {
+			if _, err := splitSQL(script); err != nil {
+				return sorted, fmt.Errorf("migration %d_%s: %w", m.Version, m.Name, err)
+			}
+		}
+	}
+
+	return sorted, nil
+}
+
+// withMigrationLock runs f while holding the migration lock, so that one instance migrates at a time
+// f is given a session on the connection holding the lock, so that it needs no other connection of the pool
+// a dry run only reads, it does not wait for the lock
+func withMigrationLock(dryRun bool, f func(ldb *gorm.DB) error) error {
+	if db == nil {
+		return fmt.Errorf("database is not set up")
+	}
+	if dryRun {
+		return f(db)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	// MySQL locks belong to a session, keep one connection for the lock
+	ctx := context.Background()
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var locked *int
+	err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", migrationLock, migrationLockTimeOut).Scan(&locked)
+	if err != nil {
+		return err
+	}
+	if locked == nil || *locked != 1 {
+		return fmt.Errorf("migrations are locked by another instance")
+	}
+	defer conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", migrationLock)
+
+	ldb := db.Session(&gorm.Session{NewDB: true, Context: ctx})
+	ldb.Statement.ConnPool = conn
+
+	return f(ldb)
+}
+
+// migrateSchemaMigrations creates the schema_migrations table when missing
+func migrateSchemaMigrations(ldb *gorm.DB) error {
+	if ldb.Migrator().HasTable(&SchemaMigration{}) {
+		return nil
+	}
+	return ldb.AutoMigrate(&SchemaMigration{})
+}
+
+// appliedMigrations returns the applied migrations by version, none when the table is missing
+func appliedMigrations(ldb *gorm.DB) (map[int64]SchemaMigration, error) {
+	applied := make(map[int64]SchemaMigration)
+	if !ldb.Migrator().HasTable(&SchemaMigration{}) {
+		return applied, nil
+	}
+
+	var rows []SchemaMigration
+	if err := ldb.Find(&rows).Error; err != nil {
+		return applied, err
+	}
+	for _, r := range rows {
+		applied[r.Version] = r
+	}
+
+	return applied, nil
+}
+
+// runMigration applies or rolls back a migration and records it
+func runMigration(ldb *gorm.DB, m Migration, up bool) error {
+	err := ldb.Transaction(func(tx *gorm.DB) error {
+		var err error
+		switch {
+		case up && m.Up != nil:
+			err = m.Up(tx)
+		case up:
+			err = execSQL(tx, m.UpSQL)
+		case m.Down != nil:
+			err = m.Down(tx)
+		default:
+			err = execSQL(tx, m.DownSQL)
+		}
+		if err != nil {
+			return err
+		}
+
+		if up {
+			return tx.Create(&SchemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+		}
+		return tx.Delete(&SchemaMigration{}, m.Version).Error
+	})
+	if err != nil {
+		direction := "up"
+		if !up {
+			direction = "down"
+		}
+		return fmt.Errorf("migration %d_%s %s: %w", m.Version, m.Name, direction, err)
+	}
+
+	return nil
+}
+
+// execSQL runs the statements of a SQL script one by one
+func execSQL(tx *gorm.DB, script string) error {
+	statements, err := splitSQL(script)
+	if err != nil {
+		return err
+	}
+	for _, statement := range statements {
+		if err := tx.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// statements a script cannot hold, as their ';' cannot be told apart
+var (
+	delimiterStatement = regexp.MustCompile(`(?im)^\s*DELIMITER\s`)
+	compoundStatement  = regexp.MustCompile(`(?is)^CREATE\s+(OR\s+REPLACE\s+)?(DEFINER\s*=\s*\S+\s+)?(PROCEDURE|FUNCTION|TRIGGER|EVENT)\b.*\bBEGIN\b`)
+)
+
+// splitSQL splits a script on ';', ignoring those in quotes and comments
+func splitSQL(script string) ([]string, error) {
+	var statements []string
+	var current strings.Builder
+	var err error
+
+	flush := func() {
+		s := strings.TrimSpace(current.String())
+		current.Reset()
+		if s == "" || err != nil {
+			return
+		}
+		if delimiterStatement.MatchString(s) {
+			err = fmt.Errorf("DELIMITER is not supported in SQL migrations, use a Go migration")
+			return
+		}
+		if compoundStatement.MatchString(s) {
+			err = fmt.Errorf("BEGIN ... END bodies are not supported in SQL migrations, use a Go migration: %.60s", s)
+			return
+		}
+		statements = append(statements, s)
+	}
+
+	var quote byte
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+
+		switch {
+		case quote != 0:
+			current.WriteByte(c)
+			if c == '\\' && quote != '`' && i+1 < len(script) {
+				i++
+				current.WriteByte(script[i])
+			} else if c == quote {
+				quote = 0
+			}
+
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+			current.WriteByte(c)
+
+		case c == '-' && isLineComment(script[i:]), c == '#':
+			// comment until end of line
+			for i < len(script) && script[i] != '\n' {
+				i++
+			}
+			current.WriteByte('\n')
+
+		case c == '/' && strings.HasPrefix(script[i:], "/*"):
+			end := strings.Index(script[i+2:], "*/")
+			if end < 0 {
+				i = len(script)
+			} else {
+				i += end + 3
+			}
+			current.WriteByte(' ')
+
+		case c == ';':
+			flush()
+
+		default:
+			current.WriteByte(c)
+		}
+	}
+	flush()
+
+	if quote != 0 && err == nil {
+		err = fmt.Errorf("unterminated %c quote in SQL script", quote)
+	}
+
+	return statements, err
+}
+
+// isLineComment tells if s starts with a "-- " comment, the space may be any whitespace or the end
+func isLineComment(s string) bool {
+	if !strings.HasPrefix(s, "--") {
+		return false
+	}
+	return len(s) == 2 || strings.ContainsRune(" \t\r\n", rune(s[2]))
+}
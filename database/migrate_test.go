@@ -0,0 +1,85 @@
+package database
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSplitSQL(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{"statements", "ALTER TABLE a ADD b INT;\nUPDATE a SET b = 1;", []string{"ALTER TABLE a ADD b INT", "UPDATE a SET b = 1"}},
+		{"quotes", "INSERT INTO a VALUES ('x;y', \"it\\\"s;\", `c;d`);", []string{"INSERT INTO a VALUES ('x;y', \"it\\\"s;\", `c;d`)"}},
+		{"comments", "-- drop; it\n# other; comment\nDROP TABLE a; /* end; */", []string{"DROP TABLE a"}},
+		{"dashes in expression", "UPDATE a SET b = b--1;", []string{"UPDATE a SET b = b--1"}},
+		{"simple trigger", "CREATE TRIGGER t BEFORE INSERT ON a FOR EACH ROW SET NEW.b = 1;", []string{"CREATE TRIGGER t BEFORE INSERT ON a FOR EACH ROW SET NEW.b = 1"}},
+	}
+
+	for _, test := range tests {
+		got, err := splitSQL(test.script)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestSplitSQLRejects(t *testing.T) {
+	scripts := map[string]string{
+		"delimiter":  "DELIMITER //\nCREATE PROCEDURE p() BEGIN SELECT 1; END //\nDELIMITER ;",
+		"procedure":  "CREATE PROCEDURE p()\nBEGIN\n  UPDATE a SET b = 1;\nEND;",
+		"definer":    "CREATE DEFINER=`root`@`%` TRIGGER t BEFORE INSERT ON a FOR EACH ROW BEGIN SET NEW.b = 1; END;",
+		"unfinished": "INSERT INTO a VALUES ('x);",
+	}
+
+	for name, script := range scripts {
+		if _, err := splitSQL(script); err == nil {
+			t.Errorf("%s: script accepted", name)
+		}
+	}
+}
+
+func TestLoadSQLMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/2_backfill.up.sql": {Data: []byte("UPDATE a SET b = 1;")},
+		"migrations/1_rename.up.sql":   {Data: []byte("ALTER TABLE a RENAME COLUMN c TO b;")},
+		"migrations/1_rename.down.sql": {Data: []byte("ALTER TABLE a RENAME COLUMN b TO c;")},
+		"migrations/README.md":         {Data: []byte("not a migration")},
+		"invalid/1_procedure.up.sql":   {Data: []byte("DELIMITER //")},
+		"missing/1_only_down.down.sql": {Data: []byte("SELECT 1;")},
+	}
+
+	migrations, err := LoadSQLMigrations(fsys, "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) != 2 || migrations[0].Version != 1 || migrations[1].Version != 2 {
+		t.Fatalf("got %+v, want versions 1 and 2", migrations)
+	}
+	if migrations[0].Name != "rename" || migrations[0].DownSQL == "" || migrations[1].DownSQL != "" {
+		t.Errorf("got %+v", migrations)
+	}
+
+	for _, dir := range []string{"invalid", "missing"} {
+		if _, err := LoadSQLMigrations(fsys, dir); err == nil {
+			t.Errorf("%s: loaded", dir)
+		}
+	}
+}
+
+func TestRunMigrationCommandUsage(t *testing.T) {
+	for _, args := range [][]string{nil, {"sideways"}, {"up", "2"}, {"down", "0"}, {"down", "x"}} {
+		var out bytes.Buffer
+		if err := RunMigrationCommand(&out, nil, args); err == nil || out.Len() > 0 {
+			t.Errorf("%q: got %v, output %q", args, err, out.String())
+		}
+	}
+}
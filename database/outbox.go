@@ -0,0 +1,326 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/remy8000/gopkg/elastic"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OutboxEntry is an elastic action waiting to be relayed
+// entries of a same doc are relayed one at a time, in insertion order
+type OutboxEntry struct {
+	Id            uint64 `gorm:"primaryKey;autoIncrement"`
+	Action        string `gorm:"size:16"`
+	EsIndex       string `gorm:"size:191;index:idx_outbox_doc,priority:1"`
+	DocId         string `gorm:"size:191;index:idx_outbox_doc,priority:2"`
+	Doc           []byte // JSON, empty for delete
+	Attempts      int
+	LastError     string     `gorm:"type:text"`
+	NextAttemptAt time.Time  `gorm:"index"`
+	DeadAt        *time.Time `gorm:"index"` // set when dead-lettered
+	CreatedAt     time.Time
+}
+
+// TableName of the outbox
+func (OutboxEntry) TableName() string {
+	return "es_outbox"
+}
+
+// OutboxRelayConfig configures the relay, zero values take defaults
+type OutboxRelayConfig struct {
+	BatchSize    int           // default 100
+	PollInterval time.Duration // wait when the outbox is empty, default 1s
+	MaxAttempts  int           // before dead-lettering, default 10
+	MinBackoff   time.Duration // default 1s, doubled on each attempt
+	MaxBackoff   time.Duration // default 5m
+	TimeOut      int           // per bulk request, in seconds, default 30
+	// claimed entries are skipped by other relays this long, it must outlast the bulk request
+	// default the bulk timeout plus 1m
+	ClaimTimeout time.Duration
+
+	OnDeadLetter func(entry OutboxEntry) // optional
+	OnError      func(err error)         // optional, errors of RunOutboxRelay passes
+}
+
+var (
+	outboxMu       sync.Mutex
+	outboxMigrated bool
+)
+
+// EnqueueOutbox adds an elastic action to the outbox within the transaction tx
+// so that it is relayed only if the transaction commits
+// the action needs an id, Doc is marshaled to JSON
+func EnqueueOutbox(tx *gorm.DB, action elastic.BulkAction) error {
+
+	// CHECKS
+	switch action.Action {
+	case elastic.BulkIndex, elastic.BulkCreate, elastic.BulkUpdate, elastic.BulkDelete:
+	default:
+		return fmt.Errorf("outbox: unknown action '%s'", action.Action)
+	}
+	if action.Index == "" || action.Id == "" {
+		return fmt.Errorf("outbox: action needs an index and an id")
+	}
+
+	// DDL must not run in tx, it would commit it
+	if err := migrateOutbox(); err != nil {
+		return err
+	}
+
+	entry := OutboxEntry{
+		Action:        action.Action,
+		EsIndex:       action.Index,
+		DocId:         action.Id,
+		NextAttemptAt: time.Now(),
+	}
+	if action.Action != elastic.BulkDelete {
+		doc, err := json.Marshal(action.Doc)
+		if err != nil {
+			return err
+		}
+		entry.Doc = doc
+	}
+
+	return tx.Create(&entry).Error
+}
+
+// RunOutboxRelay relays the outbox into elastic until ctx is done
+// several relays can run at the same time
+func RunOutboxRelay(ctx context.Context, config OutboxRelayConfig) error {
+	config = outboxDefaults(config)
+
+	for {
+		n, err := DrainOutbox(ctx, config)
+		if err != nil && config.OnError != nil && ctx.Err() == nil {
+			config.OnError(err)
+		}
+
+		if n == 0 || err != nil {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(config.PollInterval):
+			}
+		} else if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+// DrainOutbox relays one batch of ready entries, return count of handled entries
+// entries are claimed in a short transaction, then relayed, so that no lock is held during the bulk request
+// an entry is relayed again when its result cannot be recorded, a create then conflicting with the doc it created is a success
+// an item failing with 429 or 5xx is retried with backoff, other failures are dead-lettered
+func DrainOutbox(ctx context.Context, config OutboxRelayConfig) (int, error) {
+	config = outboxDefaults(config)
+
+	if db == nil {
+		return 0, fmt.Errorf("database is not set up")
+	}
+	if err := migrateOutbox(); err != nil {
+		return 0, err
+	}
+
+	entries, err := claimOutbox(ctx, config)
+	if err != nil || len(entries) == 0 {
+		return 0, err
+	}
+
+	actions := make([]elastic.BulkAction, len(entries))
+	for i, e := range entries {
+		actions[i] = elastic.BulkAction{Action: e.Action, Index: e.EsIndex, Id: e.DocId}
+		if e.Action != elastic.BulkDelete {
+			actions[i].Doc = json.RawMessage(e.Doc)
+		}
+	}
+
+	// on a request error, entries are retried when their claim expires
+	result, err := elastic.Bulk(actions, false, config.TimeOut)
+	if err != nil {
+		return 0, err
+	}
+	if len(result.Items) != len(entries) {
+		return 0, fmt.Errorf("outbox: %d bulk items for %d entries", len(result.Items), len(entries))
+	}
+
+	var done []uint64
+	var failed, dead []OutboxEntry
+	now := time.Now()
+	for i, item := range result.Items {
+		e := entries[i]
+		if !settleOutboxEntry(&e, item, config, now) {
+			done = append(done, e.Id)
+			continue
+		}
+		failed = append(failed, e)
+		if e.DeadAt != nil {
+			dead = append(dead, e)
+		}
+	}
+
+	err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, e := range failed {
+			err := tx.Model(&e).Select("attempts", "last_error", "next_attempt_at", "dead_at").Updates(&e).Error
+			if err != nil {
+				return err
+			}
+		}
+		if len(done) > 0 {
+			return tx.Delete(&OutboxEntry{}, done).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if config.OnDeadLetter != nil {
+		for _, e := range dead {
+			go config.OnDeadLetter(e)
+		}
+	}
+	return len(entries), nil
+}
+
+// claimOutbox returns the oldest ready entry of each doc, skipping those locked by another relay,
+// and delays their next attempt by the claim timeout so that other relays leave them alone
+func claimOutbox(ctx context.Context, config OutboxRelayConfig) ([]OutboxEntry, error) {
+	var entries []OutboxEntry
+
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("dead_at IS NULL AND next_attempt_at <= ?", time.Now()).
+			Where("NOT EXISTS (SELECT 1 FROM es_outbox prev WHERE prev.es_index = es_outbox.es_index AND prev.doc_id = es_outbox.doc_id AND prev.dead_at IS NULL AND prev.id < es_outbox.id)").
+			Order("id").Limit(config.BatchSize).Find(&entries).Error
+		if err != nil || len(entries) == 0 {
+			return err
+		}
+
+		ids := make([]uint64, len(entries))
+		for i, e := range entries {
+			ids[i] = e.Id
+		}
+		return tx.Model(&OutboxEntry{}).Where("id IN ?", ids).Update("next_attempt_at", time.Now().Add(config.ClaimTimeout)).Error
+	})
+
+	return entries, err
+}
+
+// settleOutboxEntry applies the bulk result of an entry, return false when the entry is done
+// a failed entry gets its next attempt, or is dead-lettered
+func settleOutboxEntry(e *OutboxEntry, item elastic.BulkItem, config OutboxRelayConfig, now time.Time) bool {
+	if item.Error == "" {
+		return false
+	}
+	// the doc was created by a previous relay of the entry whose result was not recorded
+	if e.Action == elastic.BulkCreate && item.Status == http.StatusConflict {
+		return false
+	}
+
+	e.Attempts++
+	e.LastError = item.Error
+	e.NextAttemptAt = now.Add(outboxBackoff(config, e.Attempts))
+	if !retryableStatus(item.Status) || e.Attempts >= config.MaxAttempts {
+		e.DeadAt = &now
+	}
+	return true
+}
+
+// DeadOutboxEntries returns dead-lettered entries, oldest first
+func DeadOutboxEntries(limit int) ([]OutboxEntry, error) {
+	var entries []OutboxEntry
+
+	if db == nil {
+		return entries, fmt.Errorf("database is not set up")
+	}
+	if err := migrateOutbox(); err != nil {
+		return entries, err
+	}
+
+	q := db.Where("dead_at IS NOT NULL").Order("id")
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+	err := q.Find(&entries).Error
+	return entries, err
+}
+
+// RequeueOutbox puts dead-lettered entries back in the outbox with their attempts reset
+func RequeueOutbox(ids ...uint64) error {
+	if db == nil {
+		return fmt.Errorf("database is not set up")
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	return db.Model(&OutboxEntry{}).Where("id IN ? AND dead_at IS NOT NULL", ids).
+		Updates(map[string]interface{}{"dead_at": nil, "attempts": 0, "next_attempt_at": time.Now()}).Error
+}
+
+// migrateOutbox creates the outbox table once
+func migrateOutbox() error {
+	outboxMu.Lock()
+	defer outboxMu.Unlock()
+
+	if outboxMigrated {
+		return nil
+	}
+	if db == nil {
+		return fmt.Errorf("database is not set up")
+	}
+	if err := db.AutoMigrate(&OutboxEntry{}); err != nil {
+		return err
+	}
+	outboxMigrated = true
+	return nil
+}
+
+func outboxDefaults(config OutboxRelayConfig) OutboxRelayConfig {
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = time.Second
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 10
+	}
+	if config.MinBackoff <= 0 {
+		config.MinBackoff = time.Second
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = 5 * time.Minute
+	}
+	if config.TimeOut <= 0 {
+		config.TimeOut = 30
+	}
+	if config.ClaimTimeout <= 0 {
+		config.ClaimTimeout = time.Duration(config.TimeOut)*time.Second + time.Minute
+	}
+	return config
+}
+
+// outboxBackoff returns the wait before the next attempt
+func outboxBackoff(config OutboxRelayConfig, attempts int) time.Duration {
+	backoff := config.MinBackoff
+	for i := 1; i < attempts && backoff < config.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > config.MaxBackoff {
+		backoff = config.MaxBackoff
+	}
+	return backoff
+}
+
+// retryableStatus tells if a failed bulk item may succeed later
+func retryableStatus(status int) bool {
+	return status == 429 || status >= 500
+}
@@ -0,0 +1,55 @@
+package database
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/remy8000/gopkg/elastic"
+)
+
+func TestSettleOutboxEntry(t *testing.T) {
+	config := outboxDefaults(OutboxRelayConfig{MaxAttempts: 3})
+	now := time.Now()
+
+	tests := []struct {
+		name     string
+		action   string
+		attempts int
+		item     elastic.BulkItem
+		failed   bool
+		dead     bool
+	}{
+		{"success", elastic.BulkIndex, 0, elastic.BulkItem{Status: http.StatusCreated}, false, false},
+		{"create conflict", elastic.BulkCreate, 0, elastic.BulkItem{Status: http.StatusConflict, Error: "version_conflict_engine_exception"}, false, false},
+		{"update conflict", elastic.BulkUpdate, 0, elastic.BulkItem{Status: http.StatusConflict, Error: "version_conflict_engine_exception"}, true, true},
+		{"too many requests", elastic.BulkIndex, 0, elastic.BulkItem{Status: http.StatusTooManyRequests, Error: "es_rejected_execution_exception"}, true, false},
+		{"last attempt", elastic.BulkIndex, 2, elastic.BulkItem{Status: http.StatusServiceUnavailable, Error: "unavailable"}, true, true},
+		{"bad request", elastic.BulkIndex, 0, elastic.BulkItem{Status: http.StatusBadRequest, Error: "mapper_parsing_exception"}, true, true},
+	}
+
+	for _, test := range tests {
+		e := OutboxEntry{Action: test.action, Attempts: test.attempts}
+		failed := settleOutboxEntry(&e, test.item, config, now)
+		if failed != test.failed || (e.DeadAt != nil) != test.dead {
+			t.Errorf("%s: got failed %v, dead %v", test.name, failed, e.DeadAt != nil)
+			continue
+		}
+		if failed && (e.Attempts != test.attempts+1 || e.LastError != test.item.Error || !e.NextAttemptAt.After(now)) {
+			t.Errorf("%s: got %+v", test.name, e)
+		}
+	}
+}
+
+func TestOutboxClaimTimeout(t *testing.T) {
+	config := outboxDefaults(OutboxRelayConfig{TimeOut: 10})
+	if config.ClaimTimeout != 70*time.Second {
+		t.Errorf("got %s, want the bulk timeout plus 1m", config.ClaimTimeout)
+	}
+
+	// a zero config gets the default bulk timeout
+	config = outboxDefaults(OutboxRelayConfig{})
+	if config.TimeOut != 30 || config.ClaimTimeout != 90*time.Second {
+		t.Errorf("got timeout %d, claim timeout %s", config.TimeOut, config.ClaimTimeout)
+	}
+}
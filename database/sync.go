@@ -0,0 +1,373 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/remy8000/gopkg/elastic"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// SyncState persists the watermark of a table to index sync
+// rows are synced in (change time, primary key) order, LastKey breaks ties between rows changed at the same time
+type SyncState struct {
+	Name      string `gorm:"primaryKey;size:191"`
+	Watermark time.Time
+	LastKey   string `gorm:"size:191"`
+	UpdatedAt time.Time
+}
+
+// SyncConfig describes how rows of the model T are copied into an index
+type SyncConfig[T any] struct {
+	Name  string // key of the persisted watermark, default Index
+	Index string
+
+	// Map converts a row into a doc, a nil doc removes the row from the index
+	Map func(row *T) (elastic.Doc, error)
+	// Id returns the doc id of a row, default the primary key
+	Id func(row *T) string
+
+	// UpdatedAtColumn is the change time of rows, it must be a time column and not null, default "updated_at"
+	// the table needs an index on (UpdatedAtColumn, primary key)
+	UpdatedAtColumn string
+	// DeletedColumn is the soft-delete flag, a time (e.g. gorm.DeletedAt) or a bool column
+	// default the gorm soft-delete field of the model, if any
+	// a time column is a change time too, it needs an index on (DeletedColumn, primary key)
+	DeletedColumn string
+
+	BatchSize int           // default 500
+	Overlap   time.Duration // re-sync rows changed this long before the watermark, for late commits
+	Pipeline  string        // ingest pipeline (optional)
+	TimeOut   int           // per bulk request, in seconds, default 30
+}
+
+// SyncResult reports a sync run
+type SyncResult struct {
+	Indexed   int
+	Deleted   int
+	Watermark time.Time
+}
+
+// SyncIndex copies rows changed since the last run into the index
+// the first run (or a run after ResetSync) loads the whole table
+// the watermark is saved after each batch, so an interrupted run resumes where it stopped
+func SyncIndex[T any](ctx context.Context, config SyncConfig[T]) (SyncResult, error) {
+
+	var result SyncResult
+
+	// CHECKS
+	if db == nil {
+		return result, fmt.Errorf("database is not set up")
+	}
+	if config.Index == "" {
+		return result, fmt.Errorf("sync: no index")
+	}
+	if config.Map == nil {
+		return result, fmt.Errorf("sync: no map function")
+	}
+	if config.Name == "" {
+		config.Name = config.Index
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 500
+	}
+	if config.TimeOut <= 0 {
+		config.TimeOut = 30
+	}
+
+	s, err := newSyncSchema[T](config)
+	if err != nil {
+		return result, err
+	}
+
+	if err := migrateSyncState(); err != nil {
+		return result, err
+	}
+
+	state := SyncState{Name: config.Name}
+	err = db.WithContext(ctx).Where("name = ?", config.Name).Limit(1).Find(&state).Error
+	if err != nil {
+		return result, err
+	}
+
+	watermark, lastKey := state.Watermark, state.LastKey
+	if config.Overlap > 0 && !watermark.IsZero() {
+		watermark, lastKey = watermark.Add(-config.Overlap), ""
+	}
+	result.Watermark = state.Watermark
+
+	var opts []elastic.WriteOption
+	if config.Pipeline != "" {
+		opts = append(opts, elastic.WithPipeline(config.Pipeline))
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		var rows []T
+		err := s.batchQuery(db.WithContext(ctx), new(T), watermark, lastKey, config.BatchSize).Find(&rows).Error
+		if err != nil {
+			return result, err
+		}
+		if len(rows) == 0 {
+			break
+		}
+
+		var actions []elastic.BulkAction
+		for i := range rows {
+			row := &rows[i]
+			v := reflect.ValueOf(row).Elem()
+
+			id := s.key(ctx, v)
+			if config.Id != nil {
+				id = config.Id(row)
+			}
+
+			var doc elastic.Doc
+			if !s.deleted(ctx, v) {
+				doc, err = config.Map(row)
+				if err != nil {
+					return result, fmt.Errorf("sync: map row %s: %w", s.key(ctx, v), err)
+				}
+				if doc != nil && isNilDoc(doc) {
+					return result, fmt.Errorf("sync: map row %s returned a nil %T, return a nil Doc to remove the row", s.key(ctx, v), doc)
+				}
+			}
+
+			if doc == nil {
+				actions = append(actions, elastic.BulkAction{Action: elastic.BulkDelete, Index: config.Index, Id: id})
+			} else {
+				actions = append(actions, elastic.BulkAction{Action: elastic.BulkIndex, Index: config.Index, Id: id, Doc: doc})
+			}
+		}
+
+		bulk, err := elastic.Bulk(actions, false, config.TimeOut, opts...)
+		if err != nil {
+			return result, err
+		}
+		// the watermark stays before the batch, it is synced again on the next run
+		if failed := bulk.Failed(); len(failed) > 0 {
+			return result, fmt.Errorf("sync: %d docs failed, first %s: %s", len(failed), failed[0].Id, failed[0].Error)
+		}
+		for _, a := range actions {
+			if a.Action == elastic.BulkDelete {
+				result.Deleted++
+			} else {
+				result.Indexed++
+			}
+		}
+
+		last := reflect.ValueOf(&rows[len(rows)-1]).Elem()
+		watermark, lastKey = s.changeTime(ctx, last), s.key(ctx, last)
+
+		// with an overlap, the re-synced rows must not move the watermark back
+		if config.Overlap == 0 || watermark.After(state.Watermark) {
+			state.Watermark, state.LastKey = watermark, lastKey
+			err = db.WithContext(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Create(&state).Error
+			if err != nil {
+				return result, err
+			}
+			result.Watermark = state.Watermark
+		}
+
+		if len(rows) < config.BatchSize {
+			break
+		}
+	}
+
+	return result, nil
+}
+
+// ResetSync forgets the watermark of a sync, the next run loads the whole table
+func ResetSync(name string) error {
+	if db == nil {
+		return fmt.Errorf("database is not set up")
+	}
+	if err := migrateSyncState(); err != nil {
+		return err
+	}
+	return db.Where("name = ?", name).Delete(&SyncState{}).Error
+}
+
+// migrateSyncState creates the sync state table when missing
+func migrateSyncState() error {
+	if db.Migrator().HasTable(&SyncState{}) {
+		return nil
+	}
+	return db.AutoMigrate(&SyncState{})
+}
+
+// syncSchema holds the columns of a synced model
+type syncSchema struct {
+	primary   *schema.Field
+	updatedAt *schema.Field
+	deletedAt *schema.Field // soft-delete field, nil when none
+
+	// quoted columns, the change time of a row is the latest of updated and deleted
+	primaryKey    string
+	updatedColumn string
+	deletedColumn string // set when the soft-delete column is a time
+	// unqualified, to sort the union of both
+	sortChange string
+	sortKey    string
+}
+
+var deletedAtType = reflect.TypeOf(gorm.DeletedAt{})
+
+func newSyncSchema[T any](config SyncConfig[T]) (syncSchema, error) {
+	var s syncSchema
+
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(new(T)); err != nil {
+		return s, err
+	}
+	sch := stmt.Schema
+
+	if len(sch.PrimaryFields) != 1 {
+		return s, fmt.Errorf("sync: %s must have a single primary key", sch.Name)
+	}
+	s.primary = sch.PrimaryFields[0]
+
+	column := config.UpdatedAtColumn
+	if column == "" {
+		column = "updated_at"
+	}
+	s.updatedAt = sch.LookUpField(column)
+	if s.updatedAt == nil || !isTimeField(s.updatedAt) {
+		return s, fmt.Errorf("sync: %s has no time column '%s'", sch.Name, column)
+	}
+
+	if config.DeletedColumn != "" {
+		s.deletedAt = sch.LookUpField(config.DeletedColumn)
+		if s.deletedAt == nil {
+			return s, fmt.Errorf("sync: %s has no column '%s'", sch.Name, config.DeletedColumn)
+		}
+	} else {
+		for _, f := range sch.Fields {
+			if f.FieldType == deletedAtType && f.DBName != "" {
+				s.deletedAt = f
+				break
+			}
+		}
+	}
+
+	quote := func(column string) string {
+		return stmt.Quote(clause.Column{Table: sch.Table, Name: column})
+	}
+	s.primaryKey = quote(s.primary.DBName)
+	s.updatedColumn = quote(s.updatedAt.DBName)
+	// soft deletes done by gorm do not touch updated_at
+	if s.deletedAt != nil && (s.deletedAt.FieldType == deletedAtType || isTimeField(s.deletedAt)) {
+		s.deletedColumn = quote(s.deletedAt.DBName)
+		s.sortChange = fmt.Sprintf("GREATEST(%[1]s, COALESCE(%[2]s, %[1]s))", stmt.Quote(s.updatedAt.DBName), stmt.Quote(s.deletedAt.DBName))
+		s.sortKey = stmt.Quote(s.primary.DBName)
+	}
+
+	return s, nil
+}
+
+// batchQuery returns the query of the next rows changed after (watermark, lastKey), in change order
+// with a soft-delete time, rows last updated and rows last deleted are selected apart,
+// so that each part is a range on its own indexed column and the union is sorted in memory
+func (s syncSchema) batchQuery(tx *gorm.DB, model interface{}, watermark time.Time, lastKey string, limit int) *gorm.DB {
+	after := func(column string) *gorm.DB {
+		q := tx.Unscoped().Model(model)
+		if !watermark.IsZero() || lastKey != "" {
+			q = q.Where(fmt.Sprintf("%[1]s > ? OR (%[1]s = ? AND %[2]s > ?)", column, s.primaryKey), watermark, watermark, lastKey)
+		}
+		return q.Order(column).Order(s.primaryKey).Limit(limit)
+	}
+
+	if s.deletedColumn == "" {
+		return after(s.updatedColumn)
+	}
+
+	updated := after(s.updatedColumn).Where(fmt.Sprintf("%[1]s IS NULL OR %[1]s <= %[2]s", s.deletedColumn, s.updatedColumn))
+	deleted := after(s.deletedColumn).Where(fmt.Sprintf("%s > %s", s.deletedColumn, s.updatedColumn))
+	return tx.Raw(fmt.Sprintf("(?) UNION ALL (?) ORDER BY %s, %s LIMIT ?", s.sortChange, s.sortKey), updated, deleted, limit)
+}
+
+// key returns the primary key of a row as a string
+func (s syncSchema) key(ctx context.Context, v reflect.Value) string {
+	value, _ := s.primary.ValueOf(ctx, v)
+	return fmt.Sprint(reflect.Indirect(reflect.ValueOf(value)))
+}
+
+// deleted tells if a row is soft-deleted
+func (s syncSchema) deleted(ctx context.Context, v reflect.Value) bool {
+	if s.deletedAt == nil {
+		return false
+	}
+
+	value, zero := s.deletedAt.ValueOf(ctx, v)
+	switch d := value.(type) {
+	case gorm.DeletedAt:
+		return d.Valid
+	case sql.NullTime:
+		return d.Valid
+	case bool:
+		return d
+	case *bool:
+		return d != nil && *d
+	}
+	return !zero
+}
+
+// changeTime returns the change time of a row, the latest of its updated and deleted times
+func (s syncSchema) changeTime(ctx context.Context, v reflect.Value) time.Time {
+	t := fieldTime(s.updatedAt, ctx, v)
+	if s.deletedAt != nil {
+		if d := fieldTime(s.deletedAt, ctx, v); d.After(t) {
+			t = d
+		}
+	}
+	return t
+}
+
+// fieldTime returns the value of a time field, zero when null or not a time
+func fieldTime(f *schema.Field, ctx context.Context, v reflect.Value) time.Time {
+	value, _ := f.ValueOf(ctx, v)
+	switch t := value.(type) {
+	case time.Time:
+		return t
+	case *time.Time:
+		if t != nil {
+			return *t
+		}
+	case gorm.DeletedAt:
+		if t.Valid {
+			return t.Time
+		}
+	case sql.NullTime:
+		if t.Valid {
+			return t.Time
+		}
+	}
+	return time.Time{}
+}
+
+// isNilDoc tells if a doc is a typed nil, e.g. a nil pointer
+func isNilDoc(doc elastic.Doc) bool {
+	v := reflect.ValueOf(doc)
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		return v.IsNil()
+	}
+	return false
+}
+
+// isTimeField tells if a field holds a time
+func isTimeField(f *schema.Field) bool {
+	t := f.FieldType
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t == reflect.TypeOf(time.Time{}) || t == reflect.TypeOf(sql.NullTime{})
+}
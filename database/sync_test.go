@@ -0,0 +1,100 @@
+package database
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/remy8000/gopkg/elastic"
+	mysql "gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+type syncedRow struct {
+	Id        uint64
+	Name      string
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt
+}
+
+type syncedDoc struct{ Name string }
+
+func (*syncedDoc) IsDoc() {}
+
+// useDryRun sets up a database building statements without running them
+func useDryRun(t *testing.T) {
+	t.Helper()
+
+	dryRun, err := gorm.Open(mysql.New(mysql.Config{DSN: "user:pass@tcp(localhost:3306)/app", SkipInitializeWithVersion: true}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := db
+	db = dryRun
+	t.Cleanup(func() { db = previous })
+}
+
+func TestSyncBatchQuery(t *testing.T) {
+	useDryRun(t)
+
+	s, err := newSyncSchema(SyncConfig[syncedRow]{Index: "rows"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rows []syncedRow
+	stmt := s.batchQuery(db, new(syncedRow), time.Now(), "7", 100).Find(&rows).Statement
+	sql := stmt.SQL.String()
+
+	// each part is a range on its own column, only the union is sorted on the change time
+	parts := strings.SplitN(sql, "UNION ALL", 2)
+	if len(parts) != 2 {
+		t.Fatalf("no union in %s", sql)
+	}
+	if strings.Count(sql, "GREATEST") != 1 {
+		t.Errorf("change time expression in a part: %s", sql)
+	}
+	if !strings.Contains(parts[0], "ORDER BY `synced_rows`.`updated_at`,`synced_rows`.`id`") ||
+		!strings.Contains(parts[1], "ORDER BY `synced_rows`.`deleted_at`,`synced_rows`.`id`") {
+		t.Errorf("parts not sorted on their column: %s", sql)
+	}
+	if !strings.HasSuffix(sql, "ORDER BY GREATEST(`updated_at`, COALESCE(`deleted_at`, `updated_at`)), `id` LIMIT ?") {
+		t.Errorf("union not sorted on the change time: %s", sql)
+	}
+	if len(stmt.Vars) != 9 {
+		t.Errorf("got %d vars, want 9: %v", len(stmt.Vars), stmt.Vars)
+	}
+
+	// without a soft-delete time, rows are read in updated_at order
+	type plainRow struct {
+		Id        uint64
+		UpdatedAt time.Time
+	}
+	s, err = newSyncSchema(SyncConfig[plainRow]{Index: "rows"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var plain []plainRow
+	sql = s.batchQuery(db, new(plainRow), time.Time{}, "", 100).Find(&plain).Statement.SQL.String()
+	if strings.Contains(sql, "UNION") || !strings.Contains(sql, "ORDER BY `plain_rows`.`updated_at`,`plain_rows`.`id`") {
+		t.Errorf("got %s", sql)
+	}
+}
+
+func TestIsNilDoc(t *testing.T) {
+	var typedNil *syncedDoc
+	docs := map[string]struct {
+		doc  elastic.Doc
+		want bool
+	}{
+		"typed nil": {typedNil, true},
+		"doc":       {&syncedDoc{Name: "a"}, false},
+	}
+
+	for name, test := range docs {
+		if got := isNilDoc(test.doc); got != test.want {
+			t.Errorf("%s: got %v", name, got)
+		}
+	}
+}
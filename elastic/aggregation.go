@@ -19,7 +19,7 @@ func Aggregation(index, aggregationName string, query map[string]interface{}) (m
 	// CHECKS
 	exists, err := IndexExists(index)
 	if err != nil {
-		return bucketsMap, fmt.Errorf("index exist err: %s", err)
+		return bucketsMap, fmt.Errorf("index exist err: %w", err)
 	}
 
 	if !exists {
@@ -39,9 +39,9 @@ func Aggregation(index, aggregationName string, query map[string]interface{}) (m
 	}
 
 	// Perform the request with the client.
-	res, err := req.Do(context.Background(), es)
+	res, err := req.Do(context.Background(), esTransport)
 	if err != nil {
-		return bucketsMap, fmt.Errorf("es error getting response: %s", err)
+		return bucketsMap, fmt.Errorf("es error getting response: %w", err)
 	}
 
 	// Securely close Body
@@ -55,17 +55,36 @@ func Aggregation(index, aggregationName string, query map[string]interface{}) (m
 		return bucketsMap, err
 	}
 
+	aggregations, _ := r["aggregations"].(map[string]interface{})
+	return parseBuckets(aggregations, aggregationName)
+}
+
+// parseBuckets returns the buckets of a bucket aggregation with their doc counts
+// Returns a map[bucket_name] = []map with doc_count
+func parseBuckets(aggregations map[string]interface{}, aggregationName string) (map[string][]map[string]interface{}, error) {
+
+	bucketsMap := make(map[string][]map[string]interface{})
+
 	// Extract aggregation buckets
-	aggs, found := r["aggregations"].(map[string]interface{})[aggregationName].(map[string]interface{})["buckets"]
+	agg, _ := aggregations[aggregationName].(map[string]interface{})
+	aggs, found := agg["buckets"]
 	if !found {
 		return bucketsMap, fmt.Errorf("aggregation %s not found in response", aggregationName)
 	}
 
-	buckets := aggs.([]interface{})
+	buckets, _ := aggs.([]interface{})
 	for _, element := range buckets {
-		bucket := element.(map[string]interface{})
-		key := bucket["key"].(string)
-		docCount := bucket["doc_count"].(float64)
+		bucket, ok := element.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		// numeric and date keys are given as key_as_string
+		key, ok := bucket["key_as_string"].(string)
+		if !ok {
+			key = fmt.Sprintf("%v", bucket["key"])
+		}
+		docCount, _ := bucket["doc_count"].(float64)
 
 		// Add each bucket with its document count
 		bucketsMap[key] = []map[string]interface{}{
@@ -0,0 +1,180 @@
+package elastic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// interval between two polls of AsyncSearch.Wait
+var asyncSearchPollInterval = 2 * time.Second
+
+// AsyncSearchOptions configures an async search
+// WaitForCompletion is how long the submit waits before returning a running search (elastic default 1s)
+// KeepAlive is how long results are kept in the cluster (elastic default 5d)
+type AsyncSearchOptions struct {
+	WaitForCompletion time.Duration
+	KeepAlive         time.Duration
+}
+
+// AsyncSearch is a handle on a long-running search
+// Result holds partial results while running (when IsPartial), final results when done
+type AsyncSearch struct {
+	Id             string
+	IsRunning      bool
+	IsPartial      bool
+	StartTime      time.Time
+	ExpirationTime time.Time
+	Result         SearchResult
+	Error          string // set when the search failed
+	keepAlive      time.Duration
+}
+
+// Progress returns the count of searched shards and total shards
+func (a *AsyncSearch) Progress() (int, int) {
+	s := a.Result.Shards
+	return s.Successful + s.Skipped + s.Failed, s.Total
+}
+
+// SubmitAsyncSearch starts a search which can outlive a request timeout
+// the query is a search body, aggregations are read with AsyncSearch.Result.Buckets
+// the search id is kept in the cluster until deleted or expired
+func SubmitAsyncSearch(indices []string, query map[string]interface{}, opts AsyncSearchOptions, timeOut int) (*AsyncSearch, error) {
+
+	// CHECKS
+	if err := checkSearchIndices(indices, false); err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(query)
+	if err != nil {
+		return nil, err
+	}
+
+	keepOnCompletion := true
+	// Set up the request object.
+	req := esapi.AsyncSearchSubmitRequest{
+		Index:                    indices,
+		Body:                     bytes.NewReader(body),
+		TrackTotalHits:           true,
+		KeepOnCompletion:         &keepOnCompletion,
+		KeepAlive:                opts.KeepAlive,
+		WaitForCompletionTimeout: opts.WaitForCompletion,
+	}
+
+	r, err := doRequest(req, "SubmitAsyncSearch", timeOut)
+	if err != nil {
+		return nil, err
+	}
+
+	a := parseAsyncSearch(r)
+	a.keepAlive = opts.KeepAlive
+	if a.Id == "" {
+		return a, fmt.Errorf("could not get async search id from response")
+	}
+
+	return a, nil
+}
+
+// GetAsyncSearch returns the state and (partial) results of an async search
+func GetAsyncSearch(id string, timeOut int) (*AsyncSearch, error) {
+	a := &AsyncSearch{Id: id}
+	if err := a.Poll(timeOut); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// DeleteAsyncSearch cancels a running async search and deletes its results
+func DeleteAsyncSearch(id string, timeOut int) error {
+
+	// Set up the request object.
+	req := esapi.AsyncSearchDeleteRequest{
+		DocumentID: id,
+	}
+
+	_, err := doRequest(req, "DeleteAsyncSearch", timeOut)
+	return err
+}
+
+// Poll refreshes the state and results of the search
+func (a *AsyncSearch) Poll(timeOut int) error {
+
+	// Set up the request object.
+	req := esapi.AsyncSearchGetRequest{
+		DocumentID: a.Id,
+		KeepAlive:  a.keepAlive,
+	}
+
+	r, err := doRequest(req, "GetAsyncSearch", timeOut)
+	if err != nil {
+		return err
+	}
+
+	keepAlive := a.keepAlive
+	*a = *parseAsyncSearch(r)
+	a.keepAlive = keepAlive
+
+	return nil
+}
+
+// Wait polls the search until it completes or ctx is done
+// return the final result, the search is not deleted when ctx is done
+func (a *AsyncSearch) Wait(ctx context.Context) (SearchResult, error) {
+	for a.IsRunning {
+		select {
+		case <-ctx.Done():
+			return a.Result, fmt.Errorf("waiting for async search %s: %s", a.Id, ctx.Err())
+		case <-time.After(asyncSearchPollInterval):
+		}
+
+		// each poll is bounded by the ctx deadline
+		timeOut := 30
+		if deadline, ok := ctx.Deadline(); ok {
+			if remaining := int(time.Until(deadline).Seconds()); remaining < timeOut {
+				timeOut = remaining + 1
+			}
+		}
+
+		if err := a.Poll(timeOut); err != nil {
+			return a.Result, err
+		}
+	}
+
+	if a.Error != "" {
+		return a.Result, fmt.Errorf("async search %s failed: %s", a.Id, a.Error)
+	}
+
+	return a.Result, nil
+}
+
+// Delete cancels the search if running and deletes its results
+func (a *AsyncSearch) Delete(timeOut int) error {
+	return DeleteAsyncSearch(a.Id, timeOut)
+}
+
+// parseAsyncSearch converts an async search response
+func parseAsyncSearch(r map[string]interface{}) *AsyncSearch {
+	a := &AsyncSearch{}
+	a.Id, _ = r["id"].(string)
+	a.IsRunning, _ = r["is_running"].(bool)
+	a.IsPartial, _ = r["is_partial"].(bool)
+	a.Error = errorReason(r["error"])
+
+	if ms, ok := r["start_time_in_millis"].(float64); ok {
+		a.StartTime = time.UnixMilli(int64(ms))
+	}
+	if ms, ok := r["expiration_time_in_millis"].(float64); ok {
+		a.ExpirationTime = time.UnixMilli(int64(ms))
+	}
+
+	if response, ok := r["response"].(map[string]interface{}); ok {
+		a.Result = parseSearchResult(response)
+	}
+
+	return a
+}
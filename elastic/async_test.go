@@ -0,0 +1,107 @@
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+const runningAsyncSearch = `{"id":"abc","is_running":true,"is_partial":true,
+	"start_time_in_millis":1700000000000,"expiration_time_in_millis":1700000060000,
+	"response":{"hits":{"total":{"value":2},"hits":[{"_id":"1","_source":{}}]},
+		"_shards":{"total":4,"successful":2,"skipped":1,"failed":0},
+		"aggregations":{"by_tag":{"buckets":[{"key":"go","doc_count":2},{"key":1700000000000,"key_as_string":"2023-11-14","doc_count":1}]}}}}`
+
+func TestParseAsyncSearch(t *testing.T) {
+	var r map[string]interface{}
+	json.Unmarshal([]byte(runningAsyncSearch), &r)
+
+	a := parseAsyncSearch(r)
+	if a.Id != "abc" || !a.IsRunning || !a.IsPartial || a.Error != "" {
+		t.Errorf("got %+v", a)
+	}
+	if !a.StartTime.Equal(time.UnixMilli(1700000000000)) || a.ExpirationTime.Sub(a.StartTime) != time.Minute {
+		t.Errorf("times %v, %v", a.StartTime, a.ExpirationTime)
+	}
+	if a.Result.Total != 2 || len(a.Result.Hits) != 1 {
+		t.Errorf("result %+v", a.Result)
+	}
+
+	searched, total := a.Progress()
+	if searched != 3 || total != 4 {
+		t.Errorf("progress %d/%d", searched, total)
+	}
+
+	buckets, err := a.Result.Buckets("by_tag")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buckets) != 2 || buckets["go"][0]["doc_count"] != float64(2) || buckets["2023-11-14"][0]["doc_count"] != float64(1) {
+		t.Errorf("buckets %v", buckets)
+	}
+	if _, err := a.Result.Buckets("missing"); err == nil {
+		t.Error("no error for a missing aggregation")
+	}
+
+	failed := parseAsyncSearch(map[string]interface{}{"id": "abc", "error": map[string]interface{}{"type": "search_phase_execution_exception", "reason": "all shards failed"}})
+	if failed.Error != "search_phase_execution_exception: all shards failed" {
+		t.Errorf("error %q", failed.Error)
+	}
+}
+
+func TestAsyncSearchWait(t *testing.T) {
+	interval := asyncSearchPollInterval
+	asyncSearchPollInterval = time.Millisecond
+	t.Cleanup(func() { asyncSearchPollInterval = interval })
+
+	polls := 0
+	transport := &fakeTransport{handler: func(req *http.Request) (*http.Response, error) {
+		if req.Method != http.MethodGet {
+			return jsonResponse(http.StatusOK, runningAsyncSearch), nil
+		}
+		polls++
+		if polls < 2 {
+			return jsonResponse(http.StatusOK, runningAsyncSearch), nil
+		}
+		done := strings.Replace(strings.Replace(runningAsyncSearch, `"is_running":true`, `"is_running":false`, 1), `"is_partial":true`, `"is_partial":false`, 1)
+		return jsonResponse(http.StatusOK, done), nil
+	}}
+	useTransport(t, transport)
+
+	a, err := SubmitAsyncSearch([]string{"posts"}, map[string]interface{}{"query": map[string]interface{}{"match_all": map[string]interface{}{}}}, AsyncSearchOptions{KeepAlive: time.Minute}, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	result, err := a.Wait(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if polls != 2 || a.IsRunning || result.Total != 2 {
+		t.Errorf("%d polls, running %v, total %d", polls, a.IsRunning, result.Total)
+	}
+	// the keep alive is sent with each poll
+	if n := transport.count(http.MethodGet, "/_async_search/abc"); n != 2 {
+		t.Errorf("%d polls sent, want 2", n)
+	}
+	if last := transport.requests[len(transport.requests)-1]; last.URL.Query().Get("keep_alive") != "60000ms" {
+		t.Errorf("keep_alive %q", last.URL.Query().Get("keep_alive"))
+	}
+}
+
+func TestAsyncSearchWaitCancelled(t *testing.T) {
+	var r map[string]interface{}
+	json.Unmarshal([]byte(runningAsyncSearch), &r)
+	a := parseAsyncSearch(r)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := a.Wait(ctx); err == nil {
+		t.Error("no error for a cancelled wait")
+	}
+}
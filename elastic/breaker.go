@@ -0,0 +1,271 @@
+package elastic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// circuit breaker states
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+// count of buckets of the failure-rate window
+const breakerBuckets = 10
+
+// BreakerConfig configures the circuit breaker, zero values take defaults
+// the breaker opens when at least MinRequests were done during Window with a failure rate >= FailureRate,
+// requests then fail fast during CoolDown, then HalfOpenRequests probes are let through:
+// the breaker closes if they all succeed, opens again otherwise
+// failures are network errors, timeouts and 429, 502, 503, 504 responses
+// OnStateChange is called outside of requests, one change at a time in order (e.g. to send an alert)
+type BreakerConfig struct {
+	Window           time.Duration // default 10s
+	MinRequests      int           // default 20
+	FailureRate      float64       // default 0.5
+	CoolDown         time.Duration // default 30s
+	HalfOpenRequests int           // default 1
+	OnStateChange    func(from, to BreakerState)
+}
+
+// CircuitOpenError is returned without calling the cluster while the breaker is open
+type CircuitOpenError struct {
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("elastic circuit breaker is open, retry after %s", e.RetryAfter.Round(time.Second))
+}
+
+// WithCircuitBreaker fails requests fast while the cluster is failing
+func WithCircuitBreaker(config BreakerConfig) ClientOption {
+	return func(o *clientOptions) {
+		o.breaker = &config
+	}
+}
+
+// CircuitBreakerState returns the state of the breaker, closed when disabled
+func CircuitBreakerState() BreakerState {
+	s := esTransport.current()
+	if s == nil || s.breaker == nil {
+		return BreakerClosed
+	}
+	b := s.breaker
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// breaker is an esapi.Transport failing fast while open
+type breaker struct {
+	next   esapi.Transport
+	config BreakerConfig
+
+	mu             sync.Mutex
+	state          BreakerState
+	openedAt       time.Time
+	buckets        [breakerBuckets]breakerBucket
+	probes         int
+	probeSuccesses int
+
+	// state changes waiting for OnStateChange
+	changes   [][2]BreakerState
+	notifying bool
+}
+
+// breakerBucket counts requests of a slice of the window
+type breakerBucket struct {
+	slot     int64
+	total    int
+	failures int
+}
+
+func newBreaker(next esapi.Transport, config BreakerConfig) *breaker {
+	if config.Window <= 0 {
+		config.Window = 10 * time.Second
+	}
+	if config.MinRequests <= 0 {
+		config.MinRequests = 20
+	}
+	if config.FailureRate <= 0 || config.FailureRate > 1 {
+		config.FailureRate = 0.5
+	}
+	if config.CoolDown <= 0 {
+		config.CoolDown = 30 * time.Second
+	}
+	if config.HalfOpenRequests <= 0 {
+		config.HalfOpenRequests = 1
+	}
+
+	b := &breaker{
+		next:   next,
+		config: config,
+		state:  BreakerClosed,
+	}
+	return b
+}
+
+// Perform calls the next transport unless the breaker is open
+func (b *breaker) Perform(req *http.Request) (*http.Response, error) {
+	if err := b.allow(); err != nil {
+		return nil, err
+	}
+
+	res, err := b.next.Perform(req)
+
+	// limiter waits and caller cancellations say nothing about the cluster
+	var waitErr *limiterWaitError
+	if errors.As(err, &waitErr) || errors.Is(err, context.Canceled) {
+		b.release()
+		return res, err
+	}
+
+	b.record(isBreakerFailure(res, err))
+	return res, err
+}
+
+// allow tells if a request can be done
+func (b *breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		elapsed := time.Since(b.openedAt)
+		if elapsed < b.config.CoolDown {
+			return &CircuitOpenError{RetryAfter: b.config.CoolDown - elapsed}
+		}
+		b.setState(BreakerHalfOpen)
+		fallthrough
+	case BreakerHalfOpen:
+		if b.probes >= b.config.HalfOpenRequests {
+			return &CircuitOpenError{RetryAfter: time.Second}
+		}
+		b.probes++
+	}
+
+	return nil
+}
+
+// release gives back a half-open probe of a request that did not reach the cluster
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerHalfOpen && b.probes > 0 {
+		b.probes--
+	}
+}
+
+// record counts the outcome of a request and moves the state
+func (b *breaker) record(failure bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerHalfOpen:
+		if failure {
+			b.open()
+			return
+		}
+		b.probeSuccesses++
+		if b.probeSuccesses >= b.config.HalfOpenRequests {
+			b.buckets = [breakerBuckets]breakerBucket{}
+			b.setState(BreakerClosed)
+		}
+
+	case BreakerClosed:
+		now := time.Now()
+		width := int64(b.config.Window / breakerBuckets)
+		if width <= 0 {
+			width = 1
+		}
+		slot := now.UnixNano() / width
+		bucket := &b.buckets[slot%breakerBuckets]
+		if bucket.slot != slot {
+			*bucket = breakerBucket{slot: slot}
+		}
+		bucket.total++
+		if failure {
+			bucket.failures++
+		}
+
+		// sum the buckets of the window
+		var total, failures int
+		for _, bk := range b.buckets {
+			if slot-bk.slot < breakerBuckets {
+				total += bk.total
+				failures += bk.failures
+			}
+		}
+		if total >= b.config.MinRequests && float64(failures)/float64(total) >= b.config.FailureRate {
+			b.open()
+		}
+	}
+}
+
+// open opens the breaker, mu must be held
+func (b *breaker) open() {
+	b.openedAt = time.Now()
+	b.setState(BreakerOpen)
+}
+
+// setState changes the state and notifies, mu must be held
+func (b *breaker) setState(state BreakerState) {
+	if state == b.state {
+		return
+	}
+
+	from := b.state
+	b.state = state
+	b.probes = 0
+	b.probeSuccesses = 0
+
+	if b.config.OnStateChange != nil {
+		b.changes = append(b.changes, [2]BreakerState{from, state})
+		if !b.notifying {
+			b.notifying = true
+			go b.notify()
+		}
+	}
+}
+
+// isBreakerFailure tells if a request outcome shows a failing cluster
+func isBreakerFailure(res *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch res.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// notify calls OnStateChange for the waiting changes, in order
+func (b *breaker) notify() {
+	for {
+		b.mu.Lock()
+		if len(b.changes) == 0 {
+			b.notifying = false
+			b.mu.Unlock()
+			return
+		}
+		change := b.changes[0]
+		b.changes = b.changes[1:]
+		b.mu.Unlock()
+
+		b.config.OnStateChange(change[0], change[1])
+	}
+}
@@ -0,0 +1,212 @@
+package elastic
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// switchTransport answers with status, and blocks while hold is set
+type switchTransport struct {
+	status atomic.Int32
+	calls  atomic.Int32
+	hold   chan struct{}
+}
+
+func newSwitchTransport(status int) *switchTransport {
+	s := &switchTransport{}
+	s.status.Store(int32(status))
+	return s
+}
+
+func (s *switchTransport) Perform(req *http.Request) (*http.Response, error) {
+	s.calls.Add(1)
+	if s.hold != nil {
+		<-s.hold
+	}
+	return jsonResponse(int(s.status.Load()), `{}`), nil
+}
+
+// perform performs a request with the breaker and closes the body
+func perform(t *testing.T, b *breaker) error {
+	t.Helper()
+
+	res, err := b.Perform(newRequest(t, http.MethodGet, "/posts/_doc/1", time.Second))
+	if res != nil && res.Body != nil {
+		res.Body.Close()
+	}
+	return err
+}
+
+// recordStates returns the list of state changes, filled by OnStateChange
+func recordStates(config *BreakerConfig) func() []string {
+	var mu sync.Mutex
+	var changes []string
+	config.OnStateChange = func(from, to BreakerState) {
+		mu.Lock()
+		defer mu.Unlock()
+		changes = append(changes, string(from)+">"+string(to))
+	}
+	return func() []string {
+		// the hook runs in a goroutine
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), changes...)
+	}
+}
+
+func TestBreakerTransitions(t *testing.T) {
+	next := newSwitchTransport(http.StatusServiceUnavailable)
+	config := BreakerConfig{MinRequests: 4, FailureRate: 0.5, CoolDown: 30 * time.Millisecond, HalfOpenRequests: 2}
+	changes := recordStates(&config)
+	b := newBreaker(next, config)
+
+	// failures below MinRequests keep the breaker closed
+	for i := 0; i < 3; i++ {
+		if err := perform(t, b); err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+	}
+	if b.state != BreakerClosed {
+		t.Fatalf("state = %s after 3 failures, want closed", b.state)
+	}
+
+	// closed -> open
+	perform(t, b)
+	if b.state != BreakerOpen {
+		t.Fatalf("state = %s after 4 failures, want open", b.state)
+	}
+
+	// open: fail fast without calling the cluster
+	calls := next.calls.Load()
+	err := perform(t, b)
+	var openErr *CircuitOpenError
+	if !errors.As(err, &openErr) || openErr.RetryAfter <= 0 {
+		t.Fatalf("got %v, want a CircuitOpenError with RetryAfter", err)
+	}
+	if next.calls.Load() != calls {
+		t.Error("cluster called while open")
+	}
+
+	// open -> half-open after the cool-down, probes succeed -> closed
+	time.Sleep(40 * time.Millisecond)
+	next.status.Store(http.StatusOK)
+	for i := 0; i < 2; i++ {
+		if err := perform(t, b); err != nil {
+			t.Fatalf("probe %d: %v", i, err)
+		}
+		if i == 0 && b.state != BreakerHalfOpen {
+			t.Fatalf("state = %s after the first probe, want half-open", b.state)
+		}
+	}
+	if b.state != BreakerClosed {
+		t.Fatalf("state = %s after the probes, want closed", b.state)
+	}
+
+	want := []string{"closed>open", "open>half-open", "half-open>closed"}
+	got := changes()
+	if len(got) != len(want) {
+		t.Fatalf("state changes = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("state changes = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestBreakerHalfOpenFailureReopens(t *testing.T) {
+	next := newSwitchTransport(http.StatusBadGateway)
+	b := newBreaker(next, BreakerConfig{MinRequests: 1, CoolDown: 20 * time.Millisecond})
+
+	perform(t, b)
+	if b.state != BreakerOpen {
+		t.Fatalf("state = %s, want open", b.state)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	perform(t, b)
+	if b.state != BreakerOpen {
+		t.Fatalf("state = %s after a failed probe, want open", b.state)
+	}
+	var openErr *CircuitOpenError
+	if err := perform(t, b); !errors.As(err, &openErr) {
+		t.Fatalf("got %v after a failed probe, want a CircuitOpenError", err)
+	}
+}
+
+func TestBreakerProbeLimit(t *testing.T) {
+	next := newSwitchTransport(http.StatusServiceUnavailable)
+	b := newBreaker(next, BreakerConfig{MinRequests: 1, CoolDown: 20 * time.Millisecond, HalfOpenRequests: 1})
+
+	perform(t, b)
+	time.Sleep(30 * time.Millisecond)
+
+	// the only probe is running
+	next.status.Store(http.StatusOK)
+	next.hold = make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- perform(t, b)
+	}()
+	for next.calls.Load() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	var openErr *CircuitOpenError
+	if err := perform(t, b); !errors.As(err, &openErr) {
+		t.Fatalf("got %v while the probe runs, want a CircuitOpenError", err)
+	}
+	if next.calls.Load() != 2 {
+		t.Errorf("%d calls, want 2: the second request must not probe", next.calls.Load())
+	}
+
+	close(next.hold)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if b.state != BreakerClosed {
+		t.Fatalf("state = %s after the probe, want closed", b.state)
+	}
+}
+
+func TestBreakerIgnoresLimiterAndCancel(t *testing.T) {
+	b := newBreaker(&fakeTransport{handler: func(req *http.Request) (*http.Response, error) {
+		if req.Method == http.MethodHead {
+			return nil, context.Canceled
+		}
+		return nil, &limiterWaitError{err: context.DeadlineExceeded}
+	}}, BreakerConfig{MinRequests: 1})
+
+	b.Perform(newRequest(t, http.MethodGet, "/posts/_doc/1", time.Second))
+	b.Perform(newRequest(t, http.MethodHead, "/posts", time.Second))
+	if b.state != BreakerClosed {
+		t.Fatalf("state = %s, want closed", b.state)
+	}
+}
+
+func TestBreakerErrorThroughSearch(t *testing.T) {
+	b := newBreaker(newSwitchTransport(http.StatusServiceUnavailable), BreakerConfig{MinRequests: 1, CoolDown: time.Minute})
+	useTransport(t, b)
+
+	perform(t, b)
+	if CircuitBreakerState() != BreakerOpen {
+		t.Fatalf("state = %s, want open", CircuitBreakerState())
+	}
+
+	_, _, err := Search([]string{"posts"}, map[string]interface{}{}, 5)
+	var openErr *CircuitOpenError
+	if !errors.As(err, &openErr) {
+		t.Errorf("Search: got %v, want a CircuitOpenError", err)
+	}
+
+	_, err = Aggregation("posts", "by_tag", map[string]interface{}{})
+	if !errors.As(err, &openErr) {
+		t.Errorf("Aggregation: got %v, want a CircuitOpenError", err)
+	}
+}
@@ -0,0 +1,179 @@
+package elastic
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// bulk actions
+const (
+	BulkIndex  = "index"
+	BulkCreate = "create"
+	BulkUpdate = "update"
+	BulkDelete = "delete"
+)
+
+// BulkAction is one operation of a bulk request
+// Id is optional for index and create, Doc is ignored for delete
+type BulkAction struct {
+	Action string
+	Index  string
+	Id     string
+	Doc    interface{}
+}
+
+// BulkResult reports a bulk request, items are in the order of the actions
+type BulkResult struct {
+	Succeeded int
+	Items     []BulkItem
+}
+
+// BulkItem is the result of one bulk action, Error is empty on success
+type BulkItem struct {
+	Action string
+	Index  string
+	Id     string
+	Status int
+	Result string // created, updated, deleted, noop, not_found
+	Error  string
+}
+
+// Failed returns the failed items
+func (r BulkResult) Failed() []BulkItem {
+	var failed []BulkItem
+	for _, item := range r.Items {
+		if item.Error != "" {
+			failed = append(failed, item)
+		}
+	}
+	return failed
+}
+
+// Bulk performs several actions in one request
+// an error is only returned when the whole request fails, check BulkResult.Failed for per item errors
+func Bulk(actions []BulkAction, refresh bool, timeOut int, opts ...WriteOption) (BulkResult, error) {
+	o := applyWriteOptions(opts)
+	return bulk(actions, refresh, o.pipeline, timeOut)
+}
+
+// bulk performs a bulk request, pipeline is the default ingest pipeline (optional)
+func bulk(actions []BulkAction, refresh bool, pipeline string, timeOut int) (BulkResult, error) {
+
+	var result BulkResult
+
+	// CHECKS
+	if len(actions) == 0 {
+		return result, nil
+	}
+
+	body, err := bulkBody(actions)
+	if err != nil {
+		return result, err
+	}
+
+	// Set up the request object.
+	req := esapi.BulkRequest{
+		Body:     bytes.NewReader(body),
+		Pipeline: pipeline,
+	}
+	if refresh {
+		req.Refresh = "true"
+	}
+
+	r, err := doRequest(req, "Bulk", timeOut)
+
+	// docs may have changed even on error
+	for _, a := range actions {
+		InvalidateDoc(a.Index, a.Id)
+	}
+
+	if err != nil {
+		return result, err
+	}
+
+	return parseBulkResult(r), nil
+}
+
+// bulkBody builds the NDJSON body of a bulk request
+func bulkBody(actions []BulkAction) ([]byte, error) {
+	var buf bytes.Buffer
+
+	for _, a := range actions {
+		meta := map[string]interface{}{"_index": a.Index}
+		if a.Id != "" {
+			meta["_id"] = a.Id
+		}
+
+		switch a.Action {
+		case BulkIndex, BulkCreate, BulkDelete:
+		case BulkUpdate:
+			if a.Id == "" {
+				return nil, fmt.Errorf("bulk update needs an id")
+			}
+		default:
+			return nil, fmt.Errorf("unknown bulk action '%s'", a.Action)
+		}
+		if a.Action == BulkDelete && a.Id == "" {
+			return nil, fmt.Errorf("bulk delete needs an id")
+		}
+
+		line, err := json.Marshal(map[string]interface{}{a.Action: meta})
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+
+		if a.Action == BulkDelete {
+			continue
+		}
+
+		doc := a.Doc
+		if a.Action == BulkUpdate {
+			doc = map[string]interface{}{"doc": a.Doc}
+		}
+		line, err = json.Marshal(doc)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	return buf.Bytes(), nil
+}
+
+// parseBulkResult converts a bulk response
+func parseBulkResult(r map[string]interface{}) BulkResult {
+	var result BulkResult
+
+	items, _ := r["items"].([]interface{})
+	for _, element := range items {
+		m, ok := element.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		// each item is {"<action>": {...}}
+		for action, v := range m {
+			detail, _ := v.(map[string]interface{})
+
+			item := BulkItem{Action: action}
+			item.Index, _ = detail["_index"].(string)
+			item.Id, _ = detail["_id"].(string)
+			item.Result, _ = detail["result"].(string)
+			item.Status = toInt(detail["status"])
+			if e, ok := detail["error"].(map[string]interface{}); ok {
+				item.Error = fmt.Sprintf("%v: %v", e["type"], e["reason"])
+			} else {
+				result.Succeeded++
+			}
+
+			result.Items = append(result.Items, item)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,327 @@
+package elastic
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// CacheConfig configures the read-through cache of GetDocById and GetDocsMultiIds
+// MaxEntries bounds the cache (least recently used entries are evicted), TTL bounds staleness
+type CacheConfig struct {
+	MaxEntries int
+	TTL        time.Duration
+}
+
+// CacheStats are the counters of the docs cache
+type CacheStats struct {
+	Hits          uint64
+	Misses        uint64
+	Evictions     uint64
+	Invalidations uint64
+	Entries       int
+}
+
+// docCache is an LRU cache with TTL
+// entries are keyed by index/id/source filter, byDoc lists the keys of an index/id
+type docCache struct {
+	mu      sync.Mutex
+	config  CacheConfig
+	lru     *list.List
+	entries map[string]*list.Element
+	byDoc   map[string]map[string]struct{}
+	stats   CacheStats
+	group   singleflight.Group
+
+	// versions bumped by invalidations, a fetch started before one of its doc or index is not cached
+	// fills only holds docs being fetched
+	fills        map[string]*cacheFill
+	indexVersion map[string]uint64
+
+	// links between the names a doc was read with and its concrete index (aliases)
+	links map[string]map[string]struct{}
+}
+
+// cacheFill tracks the fetches of a doc
+type cacheFill struct {
+	pending int
+	version uint64
+}
+
+// fillToken is taken before a fetch, and given to set then end
+type fillToken struct {
+	index        string
+	docKey       string
+	version      uint64
+	indexVersion uint64
+}
+
+type cacheEntry struct {
+	key     string
+	docKey  string
+	doc     map[string]interface{}
+	expires time.Time
+}
+
+// cache is nil when disabled
+var cache *docCache
+var cacheMu sync.RWMutex
+
+// EnableDocCache enables (or resets) the docs cache
+// invalidation only covers writes done with this package, writes from other clients are seen after TTL
+// only found docs are cached, missing ones are fetched again on each read
+// cached docs are shared, callers must not modify returned sources
+func EnableDocCache(config CacheConfig) {
+	c := newDocCache(config)
+
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	cache = c
+}
+
+func newDocCache(config CacheConfig) *docCache {
+	if config.MaxEntries <= 0 {
+		config.MaxEntries = 10000
+	}
+	if config.TTL <= 0 {
+		config.TTL = time.Minute
+	}
+
+	return &docCache{
+		config:       config,
+		lru:          list.New(),
+		entries:      make(map[string]*list.Element),
+		byDoc:        make(map[string]map[string]struct{}),
+		fills:        make(map[string]*cacheFill),
+		indexVersion: make(map[string]uint64),
+		links:        make(map[string]map[string]struct{}),
+	}
+}
+
+// DisableDocCache disables the docs cache and drops its entries
+func DisableDocCache() {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	cache = nil
+}
+
+// DocCacheStats returns the counters of the docs cache (zero when disabled)
+func DocCacheStats() CacheStats {
+	c := getCache()
+	if c == nil {
+		return CacheStats{}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Entries = c.lru.Len()
+	return stats
+}
+
+// InvalidateDoc removes a doc from the cache, whatever the source filter
+// the doc is also removed when cached through an alias of the index (or the index of the alias)
+func InvalidateDoc(index, id string) {
+	if c := getCache(); c != nil {
+		c.invalidate(index, id)
+	}
+}
+
+// InvalidateIndex removes all docs of an index from the cache
+func InvalidateIndex(index string) {
+	if c := getCache(); c != nil {
+		c.invalidateIndex(index)
+	}
+}
+
+func getCache() *docCache {
+	cacheMu.RLock()
+	defer cacheMu.RUnlock()
+	return cache
+}
+
+func cacheDocKey(index, id string) string {
+	return index + "\x00" + id
+}
+
+func cacheKey(index, id string, source []string) string {
+	return cacheDocKey(index, id) + "\x00" + strings.Join(source, ",")
+}
+
+// begin is called before fetching a doc, end must be called after
+func (c *docCache) begin(index, id string) fillToken {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	docKey := cacheDocKey(index, id)
+	f := c.fills[docKey]
+	if f == nil {
+		f = &cacheFill{}
+		c.fills[docKey] = f
+	}
+	f.pending++
+
+	return fillToken{index: index, docKey: docKey, version: f.version, indexVersion: c.indexVersion[index]}
+}
+
+// end releases a token of begin
+func (c *docCache) end(token fillToken) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if f := c.fills[token.docKey]; f != nil {
+		f.pending--
+		if f.pending <= 0 {
+			delete(c.fills, token.docKey)
+		}
+	}
+}
+
+// get returns a copy of a cached doc
+func (c *docCache) get(key string) (map[string]interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		c.stats.Misses++
+		return nil, false
+	}
+
+	entry := element.Value.(*cacheEntry)
+	if time.Now().After(entry.expires) {
+		c.remove(element)
+		c.stats.Misses++
+		return nil, false
+	}
+
+	c.lru.MoveToFront(element)
+	c.stats.Hits++
+	return copyDoc(entry.doc), true
+}
+
+// set stores a doc fetched with token, evicting the least recently used entries
+// concrete is the index the doc was read from, when index is an alias
+// the doc is dropped when an invalidation of the doc or its index happened during the fetch
+func (c *docCache) set(index, id, concrete string, source []string, doc map[string]interface{}, token fillToken) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if f := c.fills[token.docKey]; f == nil || f.version != token.version || c.indexVersion[index] != token.indexVersion {
+		return
+	}
+
+	// an invalidation of the concrete index during the fetch was not seen before the link
+	if concrete != "" && concrete != index && !c.linked(index, concrete) {
+		c.link(index, concrete)
+		return
+	}
+
+	key := cacheKey(index, id, source)
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+
+	entry := &cacheEntry{
+		key:     key,
+		docKey:  cacheDocKey(index, id),
+		doc:     copyDoc(doc),
+		expires: time.Now().Add(c.config.TTL),
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	if c.byDoc[entry.docKey] == nil {
+		c.byDoc[entry.docKey] = make(map[string]struct{})
+	}
+	c.byDoc[entry.docKey][key] = struct{}{}
+
+	for c.lru.Len() > c.config.MaxEntries {
+		c.remove(c.lru.Back())
+		c.stats.Evictions++
+	}
+}
+
+func (c *docCache) invalidate(index, id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, name := range c.names(index) {
+		docKey := cacheDocKey(name, id)
+		if f := c.fills[docKey]; f != nil {
+			f.version++
+		}
+		for key := range c.byDoc[docKey] {
+			c.remove(c.entries[key])
+			c.stats.Invalidations++
+		}
+	}
+}
+
+func (c *docCache) invalidateIndex(index string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, name := range c.names(index) {
+		c.indexVersion[name]++
+		prefix := name + "\x00"
+		for key, element := range c.entries {
+			if strings.HasPrefix(key, prefix) {
+				c.remove(element)
+				c.stats.Invalidations++
+			}
+		}
+	}
+}
+
+// names returns index and the names linked to it (aliases, concrete indices), mu must be held
+func (c *docCache) names(index string) []string {
+	names := []string{index}
+	seen := map[string]bool{index: true}
+	for i := 0; i < len(names); i++ {
+		for name := range c.links[names[i]] {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// linked tells if two names are linked, mu must be held
+func (c *docCache) linked(a, b string) bool {
+	_, ok := c.links[a][b]
+	return ok
+}
+
+// link links an alias and its concrete index, mu must be held
+func (c *docCache) link(a, b string) {
+	for _, pair := range [][2]string{{a, b}, {b, a}} {
+		if c.links[pair[0]] == nil {
+			c.links[pair[0]] = make(map[string]struct{})
+		}
+		c.links[pair[0]][pair[1]] = struct{}{}
+	}
+}
+
+// remove deletes an entry, mu must be held
+func (c *docCache) remove(element *list.Element) {
+	entry := element.Value.(*cacheEntry)
+	c.lru.Remove(element)
+	delete(c.entries, entry.key)
+	delete(c.byDoc[entry.docKey], entry.key)
+	if len(c.byDoc[entry.docKey]) == 0 {
+		delete(c.byDoc, entry.docKey)
+	}
+}
+
+// copyDoc copies the top level of a doc, the source is shared
+func copyDoc(doc map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(doc))
+	for k, v := range doc {
+		c[k] = v
+	}
+	return c
+}
@@ -0,0 +1,215 @@
+package elastic
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testDoc(id string) map[string]interface{} {
+	return map[string]interface{}{"id": id, "source": map[string]interface{}{"title": id}}
+}
+
+// fill caches a doc as a fetch would
+func fill(c *docCache, index, id string) {
+	token := c.begin(index, id)
+	c.set(index, id, "", nil, testDoc(id), token)
+	c.end(token)
+}
+
+func TestDocCacheEviction(t *testing.T) {
+	c := newDocCache(CacheConfig{MaxEntries: 2, TTL: time.Minute})
+
+	fill(c, "posts", "1")
+	fill(c, "posts", "2")
+	// 1 becomes the most recently used
+	if _, ok := c.get(cacheKey("posts", "1", nil)); !ok {
+		t.Fatal("doc 1 not cached")
+	}
+	fill(c, "posts", "3")
+
+	if _, ok := c.get(cacheKey("posts", "2", nil)); ok {
+		t.Error("doc 2 should have been evicted")
+	}
+	for _, id := range []string{"1", "3"} {
+		if _, ok := c.get(cacheKey("posts", id, nil)); !ok {
+			t.Errorf("doc %s should be cached", id)
+		}
+	}
+	if c.stats.Evictions != 1 || c.lru.Len() != 2 {
+		t.Errorf("evictions = %d, entries = %d, want 1 and 2", c.stats.Evictions, c.lru.Len())
+	}
+}
+
+func TestDocCacheTTL(t *testing.T) {
+	c := newDocCache(CacheConfig{MaxEntries: 10, TTL: 20 * time.Millisecond})
+
+	fill(c, "posts", "1")
+	if _, ok := c.get(cacheKey("posts", "1", nil)); !ok {
+		t.Fatal("doc not cached")
+	}
+
+	time.Sleep(40 * time.Millisecond)
+	if _, ok := c.get(cacheKey("posts", "1", nil)); ok {
+		t.Error("expired doc served")
+	}
+	if c.lru.Len() != 0 {
+		t.Errorf("expired entry kept, entries = %d", c.lru.Len())
+	}
+}
+
+func TestDocCacheInvalidationRacingFill(t *testing.T) {
+	c := newDocCache(CacheConfig{MaxEntries: 10, TTL: time.Minute})
+
+	// the doc is written while it is fetched: the fetched version is stale
+	token := c.begin("posts", "1")
+	c.invalidate("posts", "1")
+	c.set("posts", "1", "", nil, testDoc("1"), token)
+	c.end(token)
+	if _, ok := c.get(cacheKey("posts", "1", nil)); ok {
+		t.Error("stale doc cached after a doc invalidation")
+	}
+
+	token = c.begin("posts", "1")
+	c.invalidateIndex("posts")
+	c.set("posts", "1", "", nil, testDoc("1"), token)
+	c.end(token)
+	if _, ok := c.get(cacheKey("posts", "1", nil)); ok {
+		t.Error("stale doc cached after an index invalidation")
+	}
+
+	// writes of other docs and indices do not drop the fill
+	token = c.begin("posts", "1")
+	c.invalidate("posts", "2")
+	c.invalidateIndex("users")
+	c.set("posts", "1", "", nil, testDoc("1"), token)
+	c.end(token)
+	if _, ok := c.get(cacheKey("posts", "1", nil)); !ok {
+		t.Error("doc not cached after unrelated invalidations")
+	}
+
+	if len(c.fills) != 0 {
+		t.Errorf("fills kept after end: %d", len(c.fills))
+	}
+}
+
+func TestDocCacheAliasInvalidation(t *testing.T) {
+	c := newDocCache(CacheConfig{MaxEntries: 10, TTL: time.Minute})
+
+	read := func() {
+		token := c.begin("posts", "1")
+		c.set("posts", "1", "posts-v2", nil, testDoc("1"), token)
+		c.end(token)
+	}
+
+	// the first read through an alias learns the concrete index and is not cached
+	read()
+	if _, ok := c.get(cacheKey("posts", "1", nil)); ok {
+		t.Fatal("first read through an alias cached")
+	}
+	read()
+	if _, ok := c.get(cacheKey("posts", "1", nil)); !ok {
+		t.Fatal("doc not cached")
+	}
+
+	// a write to the concrete index invalidates the doc read through the alias
+	c.invalidate("posts-v2", "1")
+	if _, ok := c.get(cacheKey("posts", "1", nil)); ok {
+		t.Error("doc still cached after a write to the concrete index")
+	}
+
+	read()
+	c.invalidateIndex("posts-v2")
+	if _, ok := c.get(cacheKey("posts", "1", nil)); ok {
+		t.Error("doc still cached after an invalidation of the concrete index")
+	}
+}
+
+func TestGetDocByIdSingleflight(t *testing.T) {
+	release := make(chan struct{})
+	transport := &fakeTransport{handler: func(req *http.Request) (*http.Response, error) {
+		if req.Method == http.MethodHead {
+			return jsonResponse(http.StatusOK, ``), nil
+		}
+		<-release
+		return jsonResponse(http.StatusOK, `{"_index":"posts","_id":"1","found":true,"_source":{"title":"a"}}`), nil
+	}}
+	useTransport(t, transport)
+	EnableDocCache(CacheConfig{})
+	t.Cleanup(DisableDocCache)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			doc, err := GetDocById("posts", "1", nil, 5)
+			if err != nil || doc["id"] != "1" {
+				t.Errorf("GetDocById: %v, %v", doc, err)
+			}
+		}()
+	}
+
+	// let all the calls join the running fetch
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := transport.count(http.MethodGet, "/posts/_doc/1"); n != 1 {
+		t.Errorf("%d get requests, want 1", n)
+	}
+
+	// then served from the cache
+	if _, err := GetDocById("posts", "1", nil, 5); err != nil {
+		t.Fatal(err)
+	}
+	if n := transport.count(http.MethodGet, "/posts/_doc/1"); n != 1 {
+		t.Errorf("%d get requests after a cached read, want 1", n)
+	}
+}
+
+func TestDocCacheMissingDocs(t *testing.T) {
+	transport := &fakeTransport{handler: func(req *http.Request) (*http.Response, error) {
+		switch req.Method {
+		case http.MethodHead:
+			return jsonResponse(http.StatusOK, `{}`), nil
+		case http.MethodGet:
+			return jsonResponse(http.StatusNotFound, `{"_index":"posts","_id":"1","found":false}`), nil
+		}
+		return jsonResponse(http.StatusOK, `{"docs":[{"_index":"posts","_id":"1","found":false}]}`), nil
+	}}
+	useTransport(t, transport)
+
+	// the error of GetDocById without cache
+	_, uncachedErr := GetDocById("posts", "1", nil, 5)
+	if uncachedErr == nil {
+		t.Fatal("GetDocById: no error for a missing doc")
+	}
+
+	EnableDocCache(CacheConfig{})
+	t.Cleanup(DisableDocCache)
+
+	// missing docs are not cached
+	for i := 0; i < 2; i++ {
+		results, err := GetDocs([]DocRef{{Index: "posts", Id: "1"}}, nil, 5)
+		if err != nil || results[0].Status != DocMissing {
+			t.Fatalf("GetDocs: %v, %v", results, err)
+		}
+	}
+	if n := transport.count(http.MethodPost, "/_mget"); n != 2 {
+		t.Errorf("%d mget requests, want 2", n)
+	}
+
+	// so GetDocById after GetDocs returns the same error as without cache
+	doc, err := GetDocById("posts", "1", nil, 5)
+	if err == nil || err.Error() != uncachedErr.Error() {
+		t.Errorf("GetDocById: got %v, %v, want %v", doc, err, uncachedErr)
+	}
+	if n := transport.count(http.MethodGet, "/posts/_doc/1"); n != 2 {
+		t.Errorf("%d get requests, want 2", n)
+	}
+	if stats := DocCacheStats(); stats.Entries != 0 {
+		t.Errorf("%d cached entries, want 0", stats.Entries)
+	}
+}
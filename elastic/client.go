@@ -1,20 +1,82 @@
 package elastic
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"sync/atomic"
 
 	elasticsearch "github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
 )
 
-var es *elasticsearch.Client
+// esTransport performs the requests of the package: the client wrapped by the client options
+// Setup swaps its state atomically, so requests in flight keep the transport they started with
+var esTransport = &clientTransport{}
 
-func Setup(cfg elasticsearch.Config) error {
-	var err error
-	es, err = elasticsearch.NewClient(cfg)
+// clientTransport is an esapi.Transport forwarding to the current client
+type clientTransport struct {
+	state atomic.Pointer[clientState]
+}
+
+// clientState is what Setup creates
+type clientState struct {
+	client    *elasticsearch.Client
+	transport esapi.Transport
+	limiter   *limiter // nil when disabled
+	breaker   *breaker // nil when disabled
+}
+
+var errNotSetup = errors.New("elastic client is not set up")
+
+// Perform performs a request with the current client
+func (t *clientTransport) Perform(req *http.Request) (*http.Response, error) {
+	s := t.state.Load()
+	if s == nil {
+		return nil, errNotSetup
+	}
+	return s.transport.Perform(req)
+}
+
+// current returns the current state, nil before Setup
+func (t *clientTransport) current() *clientState {
+	return t.state.Load()
+}
+
+// ClientOption configures the client in Setup
+type ClientOption func(*clientOptions)
+
+type clientOptions struct {
+	rateLimit *RateLimit
+	breaker   *BreakerConfig
+}
+
+// Setup creates the client used by the package
+func Setup(cfg elasticsearch.Config, opts ...ClientOption) error {
+	var o clientOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	client, err := elasticsearch.NewClient(cfg)
 	if err != nil {
 		return err
 	}
+
+	s := &clientState{client: client, transport: client}
+	if o.rateLimit != nil {
+		s.limiter = newLimiter(s.transport, *o.rateLimit)
+		s.transport = s.limiter
+	}
+	// the breaker fails fast before waiting for the limiter
+	if o.breaker != nil {
+		s.breaker = newBreaker(s.transport, *o.breaker)
+		s.transport = s.breaker
+	}
+	esTransport.state.Store(s)
+
 	return nil
 }
 
@@ -22,15 +84,23 @@ type Doc interface {
 	IsDoc()
 }
 
+// ES returns the client, requests performed directly with it bypass the client options
 func ES() *elasticsearch.Client {
-	return es
+	s := esTransport.current()
+	if s == nil {
+		return nil
+	}
+	return s.client
 }
 
 // get cluster info return client and server version
 func ClusterInfo() (string, string, error) {
-	res, err := es.Info()
+	// Set up the request object.
+	req := esapi.InfoRequest{}
+
+	res, err := req.Do(context.Background(), esTransport)
 	if err != nil {
-		return "", "", err
+		return "", "", fmt.Errorf("es error getting response: %w", err)
 	}
 
 	// Securely close Body
@@ -49,5 +119,11 @@ func ClusterInfo() (string, string, error) {
 		return "", "", fmt.Errorf("error parsing the response body: %s", err)
 	}
 
-	return elasticsearch.Version, fmt.Sprintf("%v", r["version"].(map[string]interface{})["number"]), nil
+	version, _ := r["version"].(map[string]interface{})
+	number, ok := version["number"].(string)
+	if !ok {
+		return "", "", fmt.Errorf("could not get server version from response")
+	}
+
+	return elasticsearch.Version, number, nil
 }
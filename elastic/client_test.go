@@ -0,0 +1,67 @@
+package elastic
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// fakeTransport answers requests with a handler and counts them
+type fakeTransport struct {
+	mu       sync.Mutex
+	requests []*http.Request
+	handler  func(req *http.Request) (*http.Response, error)
+}
+
+func (f *fakeTransport) Perform(req *http.Request) (*http.Response, error) {
+	f.mu.Lock()
+	f.requests = append(f.requests, req)
+	f.mu.Unlock()
+
+	if f.handler == nil {
+		return jsonResponse(http.StatusOK, `{}`), nil
+	}
+	return f.handler(req)
+}
+
+// count returns the count of requests with a method and a path
+func (f *fakeTransport) count(method, path string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n := 0
+	for _, req := range f.requests {
+		if req.Method == method && req.URL.Path == path {
+			n++
+		}
+	}
+	return n
+}
+
+func jsonResponse(status int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+}
+
+// useTransport makes the package perform its requests with transport during the test
+func useTransport(t *testing.T, transport esapi.Transport) {
+	t.Helper()
+
+	state := &clientState{transport: transport}
+	if b, ok := transport.(*breaker); ok {
+		state.breaker = b
+	}
+
+	previous := esTransport.current()
+	esTransport.state.Store(state)
+	t.Cleanup(func() {
+		esTransport.state.Store(previous)
+	})
+}
@@ -0,0 +1,326 @@
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	elasticsearch "github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// cluster and index health status
+type HealthStatus string
+
+const (
+	StatusGreen  HealthStatus = "green"
+	StatusYellow HealthStatus = "yellow"
+	StatusRed    HealthStatus = "red"
+)
+
+// interval between two health requests in WaitForStatus
+var waitForStatusInterval = 5 * time.Second
+
+// Health is the cluster health
+type Health struct {
+	ClusterName         string
+	Status              HealthStatus
+	TimedOut            bool
+	NumberOfNodes       int
+	NumberOfDataNodes   int
+	ActiveShards        int
+	RelocatingShards    int
+	InitializingShards  int
+	UnassignedShards    int
+	PendingTasks        int
+	ActiveShardsPercent float64
+	Indices             map[string]IndexHealth
+}
+
+// IndexHealth is the health of an index
+type IndexHealth struct {
+	Status             HealthStatus
+	ActiveShards       int
+	UnassignedShards   int
+	InitializingShards int
+}
+
+// NodeStats summarizes a node stats
+type NodeStats struct {
+	Id              string
+	Name            string
+	Roles           []string
+	HeapUsedPercent int
+	CpuPercent      int
+	DiskTotalBytes  int64
+	DiskFreeBytes   int64
+	DocsCount       int64
+	StoreSizeBytes  int64
+}
+
+// AtLeast tells if the status is at least as good as the given one (green > yellow > red)
+func (s HealthStatus) AtLeast(status HealthStatus) bool {
+	rank := map[HealthStatus]int{StatusRed: 0, StatusYellow: 1, StatusGreen: 2}
+	return rank[s] >= rank[status]
+}
+
+// ClusterHealth returns the cluster health, with the health of each index when indices are given
+func ClusterHealth(indices []string, timeOut int) (Health, error) {
+
+	var health Health
+
+	// Set up the request object.
+	req := esapi.ClusterHealthRequest{
+		Index: indices,
+	}
+	if len(indices) > 0 {
+		req.Level = "indices"
+	}
+
+	r, err := doRequest(req, "ClusterHealth", timeOut)
+	if err != nil {
+		return health, err
+	}
+
+	return parseHealth(r), nil
+}
+
+// WaitForStatus blocks until the cluster (or the given indices) reaches at least the status
+// unavailable cluster errors are retried until ctx is done, e.g. for services startup ordering
+func WaitForStatus(ctx context.Context, status HealthStatus, indices ...string) error {
+	var lastErr error
+
+	for {
+		health, err := waitForStatus(ctx, status, indices)
+		if err == nil && health.Status.AtLeast(status) && !health.TimedOut {
+			return nil
+		}
+		if err != nil {
+			lastErr = err
+		} else {
+			lastErr = fmt.Errorf("cluster status is %s", health.Status)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for status %s: %s (last: %s)", status, ctx.Err(), lastErr)
+		case <-time.After(waitForStatusInterval):
+		}
+	}
+}
+
+// waitForStatus performs a single health request waiting server side for the status
+// a 408 response (wait timed out) still holds the health
+func waitForStatus(ctx context.Context, status HealthStatus, indices []string) (Health, error) {
+
+	var health Health
+
+	// server side wait, bounded by the ctx deadline
+	wait := 30 * time.Second
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+		wait = time.Until(deadline)
+	}
+	if wait < time.Second {
+		wait = time.Second
+	}
+
+	// Set up the request object.
+	req := esapi.ClusterHealthRequest{
+		Index:         indices,
+		WaitForStatus: string(status),
+		Timeout:       wait,
+	}
+
+	// Perform the request with the client.
+	res, err := req.Do(ctx, esTransport)
+	if err != nil {
+		return health, fmt.Errorf("es error getting response: %w", err)
+	}
+
+	// Securely close Body
+	if res.Body != nil {
+		defer res.Body.Close()
+	}
+
+	if res.StatusCode == http.StatusRequestTimeout {
+		var r map[string]interface{}
+		if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+			return health, fmt.Errorf("error parsing the response body: %s", err)
+		}
+		return parseHealth(r), nil
+	}
+
+	//  deserialize response and possible errors
+	r, err := getResponseMap(res)
+	if err != nil {
+		return health, err
+	}
+
+	return parseHealth(r), nil
+}
+
+// parseHealth converts a cluster health response
+func parseHealth(r map[string]interface{}) Health {
+	var h Health
+	h.ClusterName, _ = r["cluster_name"].(string)
+	status, _ := r["status"].(string)
+	h.Status = HealthStatus(status)
+	h.TimedOut, _ = r["timed_out"].(bool)
+	h.NumberOfNodes = toInt(r["number_of_nodes"])
+	h.NumberOfDataNodes = toInt(r["number_of_data_nodes"])
+	h.ActiveShards = toInt(r["active_shards"])
+	h.RelocatingShards = toInt(r["relocating_shards"])
+	h.InitializingShards = toInt(r["initializing_shards"])
+	h.UnassignedShards = toInt(r["unassigned_shards"])
+	h.PendingTasks = toInt(r["number_of_pending_tasks"])
+	h.ActiveShardsPercent, _ = r["active_shards_percent_as_number"].(float64)
+
+	if indices, ok := r["indices"].(map[string]interface{}); ok {
+		h.Indices = make(map[string]IndexHealth, len(indices))
+		for name, element := range indices {
+			index, ok := element.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			status, _ := index["status"].(string)
+			h.Indices[name] = IndexHealth{
+				Status:             HealthStatus(status),
+				ActiveShards:       toInt(index["active_shards"]),
+				UnassignedShards:   toInt(index["unassigned_shards"]),
+				InitializingShards: toInt(index["initializing_shards"]),
+			}
+		}
+	}
+
+	return h
+}
+
+// NodesStats returns a summary of each node stats
+func NodesStats(timeOut int) ([]NodeStats, error) {
+
+	var nodes []NodeStats
+
+	// Set up the request object.
+	req := esapi.NodesStatsRequest{
+		Metric: []string{"jvm", "os", "fs", "indices"},
+	}
+
+	r, err := doRequest(req, "NodesStats", timeOut)
+	if err != nil {
+		return nodes, err
+	}
+
+	list, _ := r["nodes"].(map[string]interface{})
+	for id, element := range list {
+		node, ok := element.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		n := NodeStats{Id: id}
+		n.Name, _ = node["name"].(string)
+		n.Roles = toStrings(node["roles"])
+
+		jvm, _ := node["jvm"].(map[string]interface{})
+		mem, _ := jvm["mem"].(map[string]interface{})
+		n.HeapUsedPercent = toInt(mem["heap_used_percent"])
+
+		os, _ := node["os"].(map[string]interface{})
+		cpu, _ := os["cpu"].(map[string]interface{})
+		n.CpuPercent = toInt(cpu["percent"])
+
+		fs, _ := node["fs"].(map[string]interface{})
+		fsTotal, _ := fs["total"].(map[string]interface{})
+		n.DiskTotalBytes = toInt64(fsTotal["total_in_bytes"])
+		n.DiskFreeBytes = toInt64(fsTotal["available_in_bytes"])
+
+		indices, _ := node["indices"].(map[string]interface{})
+		docs, _ := indices["docs"].(map[string]interface{})
+		store, _ := indices["store"].(map[string]interface{})
+		n.DocsCount = toInt64(docs["count"])
+		n.StoreSizeBytes = toInt64(store["size_in_bytes"])
+
+		nodes = append(nodes, n)
+	}
+
+	return nodes, nil
+}
+
+// CheckServerVersion refuses to run against an unsupported server
+// the server must have the major version of the client and be at least minVersion (e.g. "8.10.0", empty to skip)
+func CheckServerVersion(minVersion string) error {
+	_, server, err := ClusterInfo()
+	if err != nil {
+		return err
+	}
+	return checkVersion(elasticsearch.Version, server, minVersion)
+}
+
+// checkVersion compares client, server and minimum versions
+func checkVersion(client, server, minVersion string) error {
+	c, err := parseVersion(client)
+	if err != nil {
+		return fmt.Errorf("client version: %s", err)
+	}
+	s, err := parseVersion(server)
+	if err != nil {
+		return fmt.Errorf("server version: %s", err)
+	}
+
+	if c[0] != s[0] {
+		return fmt.Errorf("unsupported server version %s for client %s", server, client)
+	}
+
+	if minVersion != "" {
+		m, err := parseVersion(minVersion)
+		if err != nil {
+			return fmt.Errorf("min version: %s", err)
+		}
+		for i := range s {
+			if s[i] != m[i] {
+				if s[i] < m[i] {
+					return fmt.Errorf("unsupported server version %s, at least %s is required", server, minVersion)
+				}
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+// parseVersion parses "major.minor.patch", a suffix like "-SNAPSHOT" is ignored
+func parseVersion(v string) ([3]int, error) {
+	var version [3]int
+
+	v = strings.SplitN(v, "-", 2)[0]
+	parts := strings.Split(v, ".")
+	if len(parts) == 0 || len(parts) > 3 {
+		return version, fmt.Errorf("invalid version '%s'", v)
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return version, fmt.Errorf("invalid version '%s'", v)
+		}
+		version[i] = n
+	}
+
+	return version, nil
+}
+
+// toInt converts a decoded JSON number
+func toInt(v interface{}) int {
+	f, _ := v.(float64)
+	return int(f)
+}
+
+// toInt64 converts a decoded JSON number
+func toInt64(v interface{}) int64 {
+	f, _ := v.(float64)
+	return int64(f)
+}
@@ -0,0 +1,157 @@
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHealthStatusAtLeast(t *testing.T) {
+	tests := []struct {
+		status, min HealthStatus
+		want        bool
+	}{
+		{StatusGreen, StatusYellow, true},
+		{StatusYellow, StatusYellow, true},
+		{StatusYellow, StatusGreen, false},
+		{StatusRed, StatusYellow, false},
+		{"", StatusRed, true},
+		{"", StatusYellow, false},
+	}
+
+	for _, test := range tests {
+		if got := test.status.AtLeast(test.min); got != test.want {
+			t.Errorf("%q at least %q: got %v", test.status, test.min, got)
+		}
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		want    [3]int
+		wantErr bool
+	}{
+		{"8.19.0", [3]int{8, 19, 0}, false},
+		{"8.11", [3]int{8, 11, 0}, false},
+		{"9.0.0-SNAPSHOT", [3]int{9, 0, 0}, false},
+		{"8.x", [3]int{}, true},
+		{"1.2.3.4", [3]int{}, true},
+		{"", [3]int{}, true},
+	}
+
+	for _, test := range tests {
+		got, err := parseVersion(test.version)
+		if (err != nil) != test.wantErr || (!test.wantErr && got != test.want) {
+			t.Errorf("%q: got %v, %v", test.version, got, err)
+		}
+	}
+}
+
+func TestCheckVersion(t *testing.T) {
+	tests := []struct {
+		client, server, min string
+		wantErr             bool
+	}{
+		{"8.19.0", "8.15.2", "", false},
+		{"8.19.0", "7.17.0", "", true},
+		{"8.19.0", "8.15.2", "8.10.0", false},
+		{"8.19.0", "8.15.2", "8.15.2", false},
+		{"8.19.0", "8.15.2", "8.16", true},
+		{"8.19.0", "8.15.2", "bad", true},
+	}
+
+	for _, test := range tests {
+		if err := checkVersion(test.client, test.server, test.min); (err != nil) != test.wantErr {
+			t.Errorf("client %s, server %s, min %q: got %v", test.client, test.server, test.min, err)
+		}
+	}
+}
+
+func TestToInt(t *testing.T) {
+	if toInt(float64(42)) != 42 || toInt("42") != 0 || toInt(nil) != 0 {
+		t.Error("toInt")
+	}
+	if toInt64(float64(1<<40)) != 1<<40 || toInt64(true) != 0 {
+		t.Error("toInt64")
+	}
+}
+
+func TestParseHealth(t *testing.T) {
+	var r map[string]interface{}
+	err := json.Unmarshal([]byte(`{"cluster_name":"es","status":"yellow","timed_out":false,"number_of_nodes":3,"number_of_data_nodes":2,
+		"active_shards":10,"relocating_shards":1,"initializing_shards":2,"unassigned_shards":3,"number_of_pending_tasks":4,
+		"active_shards_percent_as_number":62.5,
+		"indices":{"posts":{"status":"green","active_shards":2,"unassigned_shards":0,"initializing_shards":0},"bad":"x"}}`), &r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := parseHealth(r)
+	want := Health{ClusterName: "es", Status: StatusYellow, NumberOfNodes: 3, NumberOfDataNodes: 2, ActiveShards: 10,
+		RelocatingShards: 1, InitializingShards: 2, UnassignedShards: 3, PendingTasks: 4, ActiveShardsPercent: 62.5,
+		Indices: map[string]IndexHealth{"posts": {Status: StatusGreen, ActiveShards: 2}}}
+	if !reflect.DeepEqual(h, want) {
+		t.Errorf("got %+v, want %+v", h, want)
+	}
+}
+
+func TestNodesStats(t *testing.T) {
+	useTransport(t, &fakeTransport{handler: func(req *http.Request) (*http.Response, error) {
+		return jsonResponse(http.StatusOK, `{"nodes":{"n1":{"name":"node-1","roles":["data","master"],
+			"jvm":{"mem":{"heap_used_percent":40}},"os":{"cpu":{"percent":12}},
+			"fs":{"total":{"total_in_bytes":1000,"available_in_bytes":600}},
+			"indices":{"docs":{"count":5},"store":{"size_in_bytes":300}}}}}`), nil
+	}})
+
+	nodes, err := NodesStats(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []NodeStats{{Id: "n1", Name: "node-1", Roles: []string{"data", "master"}, HeapUsedPercent: 40, CpuPercent: 12,
+		DiskTotalBytes: 1000, DiskFreeBytes: 600, DocsCount: 5, StoreSizeBytes: 300}}
+	if !reflect.DeepEqual(nodes, want) {
+		t.Errorf("got %+v", nodes)
+	}
+}
+
+func TestWaitForStatus(t *testing.T) {
+	interval := waitForStatusInterval
+	waitForStatusInterval = time.Millisecond
+	t.Cleanup(func() { waitForStatusInterval = interval })
+
+	// unavailable, then a timed out wait, then green
+	var calls int32
+	useTransport(t, &fakeTransport{handler: func(req *http.Request) (*http.Response, error) {
+		switch atomic.AddInt32(&calls, 1) {
+		case 1:
+			return jsonResponse(http.StatusServiceUnavailable, `{"error":{"type":"master_not_discovered_exception","reason":"no master"}}`), nil
+		case 2:
+			return jsonResponse(http.StatusRequestTimeout, `{"status":"red","timed_out":true}`), nil
+		}
+		return jsonResponse(http.StatusOK, `{"status":"green","timed_out":false}`), nil
+	}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := WaitForStatus(ctx, StatusYellow); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 3 {
+		t.Errorf("%d health requests, want 3", n)
+	}
+
+	// the last error is reported when ctx is done
+	useTransport(t, &fakeTransport{handler: func(req *http.Request) (*http.Response, error) {
+		return jsonResponse(http.StatusRequestTimeout, `{"status":"red","timed_out":true}`), nil
+	}})
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := WaitForStatus(ctx, StatusGreen, "posts"); err == nil {
+		t.Error("no error for a red cluster")
+	}
+}
@@ -0,0 +1,89 @@
+package elastic
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// name of the aggregation added to count collapse groups
+const totalGroupsAggregation = "collapse_total_groups"
+
+// Collapse de-duplicates search results on a keyword or numeric field
+// one hit is returned per distinct value, InnerHits returns the top hits of each group
+// CountGroups adds a cardinality aggregation to get the (approximate) number of groups
+type Collapse struct {
+	Field                      string      `json:"field"`
+	InnerHits                  []InnerHits `json:"inner_hits,omitempty"`
+	MaxConcurrentGroupSearches int         `json:"max_concurrent_group_searches,omitempty"`
+	CountGroups                bool        `json:"-"`
+}
+
+// InnerHits returns the top hits of each collapse group
+// Name is required when several inner hits are requested
+type InnerHits struct {
+	Name   string        `json:"name,omitempty"`
+	Size   int           `json:"size,omitempty"`
+	From   int           `json:"from,omitempty"`
+	Sort   []interface{} `json:"sort,omitempty"`
+	Source []string      `json:"_source,omitempty"`
+}
+
+// addTotalGroupsAggregation adds a cardinality aggregation on the collapse field
+// aggregations of the query are kept, typed ones are converted into plain JSON
+func addTotalGroupsAggregation(q map[string]interface{}, field string) error {
+	key := "aggs"
+	if _, ok := q["aggregations"]; ok {
+		key = "aggregations"
+	}
+
+	aggs := make(map[string]interface{})
+	switch existing := q[key].(type) {
+	case nil:
+	case map[string]interface{}:
+		for k, v := range existing {
+			aggs[k] = v
+		}
+	default:
+		b, err := json.Marshal(existing)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		if err := json.Unmarshal(b, &aggs); err != nil {
+			return fmt.Errorf("%s must be an object: %w", key, err)
+		}
+	}
+	if _, ok := aggs[totalGroupsAggregation]; ok {
+		return fmt.Errorf("aggregation name %s is reserved to count collapse groups", totalGroupsAggregation)
+	}
+
+	aggs[totalGroupsAggregation] = map[string]interface{}{
+		"cardinality": map[string]interface{}{"field": field},
+	}
+	q[key] = aggs
+	return nil
+}
+
+// setCollapseResult sets group keys of hits and the total groups count
+// the aggregation counting groups is removed from the result aggregations
+func setCollapseResult(result *SearchResult, r map[string]interface{}, collapse *Collapse) {
+	for i := range result.Hits {
+		if values := result.Hits[i].Fields[collapse.Field]; len(values) > 0 {
+			result.Hits[i].CollapseKey = values[0]
+		}
+	}
+
+	if !collapse.CountGroups {
+		return
+	}
+	aggs, _ := r["aggregations"].(map[string]interface{})
+	if agg, ok := aggs[totalGroupsAggregation].(map[string]interface{}); ok {
+		if value, ok := agg["value"].(float64); ok {
+			result.TotalGroups = int(value)
+		}
+	}
+
+	delete(result.Aggregations, totalGroupsAggregation)
+	if len(result.Aggregations) == 0 {
+		result.Aggregations = nil
+	}
+}
@@ -0,0 +1,79 @@
+package elastic
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestAddTotalGroupsAggregation(t *testing.T) {
+	type terms struct {
+		Terms map[string]string `json:"terms"`
+	}
+
+	tests := []struct {
+		name    string
+		query   map[string]interface{}
+		key     string
+		want    []string
+		wantErr bool
+	}{
+		{"no aggs", map[string]interface{}{}, "aggs", []string{totalGroupsAggregation}, false},
+		{"plain aggs", map[string]interface{}{"aggs": map[string]interface{}{"tags": map[string]interface{}{}}}, "aggs", []string{"tags", totalGroupsAggregation}, false},
+		{"typed aggs", map[string]interface{}{"aggregations": map[string]map[string]interface{}{"tags": {"terms": map[string]interface{}{"field": "tag"}}}}, "aggregations", []string{"tags", totalGroupsAggregation}, false},
+		{"struct aggs", map[string]interface{}{"aggs": map[string]terms{"tags": {Terms: map[string]string{"field": "tag"}}}}, "aggs", []string{"tags", totalGroupsAggregation}, false},
+		{"not an object", map[string]interface{}{"aggs": []string{"tags"}}, "", nil, true},
+		{"reserved name", map[string]interface{}{"aggs": map[string]interface{}{totalGroupsAggregation: map[string]interface{}{}}}, "", nil, true},
+	}
+
+	for _, test := range tests {
+		err := addTotalGroupsAggregation(test.query, "user")
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: no error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+
+		aggs, _ := test.query[test.key].(map[string]interface{})
+		if len(aggs) != len(test.want) {
+			t.Errorf("%s: got %v", test.name, aggs)
+		}
+		for _, name := range test.want {
+			if aggs[name] == nil {
+				t.Errorf("%s: no aggregation %s in %v", test.name, name, aggs)
+			}
+		}
+	}
+}
+
+func TestSearchCollapseCountGroups(t *testing.T) {
+	var sent map[string]interface{}
+	useTransport(t, &fakeTransport{handler: func(req *http.Request) (*http.Response, error) {
+		b, _ := io.ReadAll(req.Body)
+		json.Unmarshal(b, &sent)
+		return jsonResponse(http.StatusOK, `{"hits":{"total":{"value":2},"hits":[{"_id":"1","fields":{"user":["a"]}}]},
+			"aggregations":{"collapse_total_groups":{"value":3},"tags":{"buckets":[]}}}`), nil
+	}})
+
+	query := map[string]interface{}{"aggs": map[string]map[string]interface{}{"tags": {"terms": map[string]interface{}{"field": "tag"}}}}
+	result, err := SearchWithOptions([]string{"posts-*"}, query, SearchOptions{Collapse: &Collapse{Field: "user", CountGroups: true}}, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if aggs, _ := sent["aggs"].(map[string]interface{}); aggs["tags"] == nil || aggs[totalGroupsAggregation] == nil {
+		t.Errorf("sent aggs %v", sent["aggs"])
+	}
+	if result.TotalGroups != 3 || result.Hits[0].CollapseKey != "a" {
+		t.Errorf("got %d groups, key %v", result.TotalGroups, result.Hits[0].CollapseKey)
+	}
+	if _, ok := result.Aggregations[totalGroupsAggregation]; ok || result.Aggregations["tags"] == nil {
+		t.Errorf("got aggregations %v", result.Aggregations)
+	}
+}
@@ -0,0 +1,239 @@
+package elastic
+
+import (
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	elasticsearch "github.com/elastic/go-elasticsearch/v8"
+	"gopkg.in/yaml.v3"
+)
+
+// Config describes the connection to the cluster
+// it can be read from a YAML file and/or the environment (env overrides the file)
+// durations are written as "5s", "1m"..., lists in env are comma separated
+type Config struct {
+	Addresses []string `yaml:"addresses" env:"ES_ADDRESSES"`
+	CloudID   string   `yaml:"cloud_id" env:"ES_CLOUD_ID"`
+
+	// authentication: api key or basic auth
+	APIKey   string `yaml:"api_key" env:"ES_API_KEY"`
+	Username string `yaml:"username" env:"ES_USERNAME"`
+	Password string `yaml:"password" env:"ES_PASSWORD"`
+
+	// TLS: custom CA (PEM file) and/or SHA256 fingerprint of the server certificate
+	CACertPath             string `yaml:"ca_cert" env:"ES_CA_CERT"`
+	CertificateFingerprint string `yaml:"certificate_fingerprint" env:"ES_CERT_FINGERPRINT"`
+	InsecureSkipVerify     bool   `yaml:"insecure_skip_verify" env:"ES_INSECURE_SKIP_VERIFY"`
+
+	CompressRequestBody   bool          `yaml:"compress_request_body" env:"ES_COMPRESS_REQUEST_BODY"`
+	DiscoverNodesOnStart  bool          `yaml:"discover_nodes_on_start" env:"ES_DISCOVER_NODES_ON_START"`
+	DiscoverNodesInterval time.Duration `yaml:"discover_nodes_interval" env:"ES_DISCOVER_NODES_INTERVAL"`
+	MaxRetries            int           `yaml:"max_retries" env:"ES_MAX_RETRIES"`
+
+	// transport timeouts, zero keeps Go defaults
+	DialTimeout           time.Duration `yaml:"dial_timeout" env:"ES_DIAL_TIMEOUT"`
+	TLSHandshakeTimeout   time.Duration `yaml:"tls_handshake_timeout" env:"ES_TLS_HANDSHAKE_TIMEOUT"`
+	ResponseHeaderTimeout time.Duration `yaml:"response_header_timeout" env:"ES_RESPONSE_HEADER_TIMEOUT"`
+	IdleConnTimeout       time.Duration `yaml:"idle_conn_timeout" env:"ES_IDLE_CONN_TIMEOUT"`
+	MaxIdleConnsPerHost   int           `yaml:"max_idle_conns_per_host" env:"ES_MAX_IDLE_CONNS_PER_HOST"`
+}
+
+// LoadConfig reads the config from a YAML file (skipped when path is empty)
+// then overrides it with the environment variables that are set
+func LoadConfig(path string) (Config, error) {
+	var c Config
+
+	if path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return c, err
+		}
+		if err := yaml.Unmarshal(b, &c); err != nil {
+			return c, fmt.Errorf("error parsing %s: %s", path, err)
+		}
+	}
+
+	if err := c.loadEnv(); err != nil {
+		return c, err
+	}
+
+	return c, nil
+}
+
+// loadEnv overrides fields with the env variables named in their env tag
+func (c *Config) loadEnv() error {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := field.Tag.Get("env")
+		value, ok := os.LookupEnv(name)
+		if name == "" || !ok {
+			continue
+		}
+
+		f := v.Field(i)
+		switch {
+		case field.Type == reflect.TypeOf(time.Duration(0)):
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("%s: %s", name, err)
+			}
+			f.SetInt(int64(d))
+		case f.Kind() == reflect.String:
+			f.SetString(value)
+		case f.Kind() == reflect.Bool:
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("%s: %s", name, err)
+			}
+			f.SetBool(b)
+		case f.Kind() == reflect.Int:
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("%s: %s", name, err)
+			}
+			f.SetInt(int64(n))
+		case f.Kind() == reflect.Slice && f.Type().Elem().Kind() == reflect.String:
+			var list []string
+			for _, s := range strings.Split(value, ",") {
+				if s = strings.TrimSpace(s); s != "" {
+					list = append(list, s)
+				}
+			}
+			f.Set(reflect.ValueOf(list))
+		default:
+			return fmt.Errorf("%s: unsupported field type %s", name, field.Type)
+		}
+	}
+
+	return nil
+}
+
+// Validate checks the config before any connection
+func (c Config) Validate() error {
+	if len(c.Addresses) == 0 && c.CloudID == "" {
+		return fmt.Errorf("elastic config: addresses or cloud_id is required")
+	}
+	if len(c.Addresses) > 0 && c.CloudID != "" {
+		return fmt.Errorf("elastic config: addresses and cloud_id are exclusive")
+	}
+	for _, address := range c.Addresses {
+		u, err := url.Parse(address)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("elastic config: invalid address '%s', expected http(s)://host:port", address)
+		}
+	}
+
+	if c.APIKey != "" && (c.Username != "" || c.Password != "") {
+		return fmt.Errorf("elastic config: api_key and username/password are exclusive")
+	}
+	if c.Password != "" && c.Username == "" {
+		return fmt.Errorf("elastic config: password without username")
+	}
+
+	if c.CACertPath != "" {
+		if _, err := readCACert(c.CACertPath); err != nil {
+			return fmt.Errorf("elastic config: %s", err)
+		}
+	}
+	if c.CertificateFingerprint != "" {
+		if b, err := hex.DecodeString(c.CertificateFingerprint); err != nil || len(b) != 32 {
+			return fmt.Errorf("elastic config: certificate_fingerprint must be a SHA256 hex string")
+		}
+	}
+	if c.InsecureSkipVerify && (c.CACertPath != "" || c.CertificateFingerprint != "") {
+		return fmt.Errorf("elastic config: insecure_skip_verify cannot be used with ca_cert or certificate_fingerprint")
+	}
+
+	if c.DiscoverNodesInterval < 0 || c.DialTimeout < 0 || c.TLSHandshakeTimeout < 0 ||
+		c.ResponseHeaderTimeout < 0 || c.IdleConnTimeout < 0 || c.MaxRetries < 0 || c.MaxIdleConnsPerHost < 0 {
+		return fmt.Errorf("elastic config: durations and counts cannot be negative")
+	}
+
+	return nil
+}
+
+// ClientConfig validates the config and builds the client config
+func (c Config) ClientConfig() (elasticsearch.Config, error) {
+	var cfg elasticsearch.Config
+
+	if err := c.Validate(); err != nil {
+		return cfg, err
+	}
+
+	cfg = elasticsearch.Config{
+		Addresses:              c.Addresses,
+		CloudID:                c.CloudID,
+		APIKey:                 c.APIKey,
+		Username:               c.Username,
+		Password:               c.Password,
+		CertificateFingerprint: c.CertificateFingerprint,
+		CompressRequestBody:    c.CompressRequestBody,
+		DiscoverNodesOnStart:   c.DiscoverNodesOnStart,
+		DiscoverNodesInterval:  c.DiscoverNodesInterval,
+		MaxRetries:             c.MaxRetries,
+	}
+
+	if c.CACertPath != "" {
+		cert, err := readCACert(c.CACertPath)
+		if err != nil {
+			return cfg, err
+		}
+		cfg.CACert = cert
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if c.DialTimeout > 0 {
+		transport.DialContext = (&net.Dialer{Timeout: c.DialTimeout, KeepAlive: 30 * time.Second}).DialContext
+	}
+	if c.TLSHandshakeTimeout > 0 {
+		transport.TLSHandshakeTimeout = c.TLSHandshakeTimeout
+	}
+	if c.ResponseHeaderTimeout > 0 {
+		transport.ResponseHeaderTimeout = c.ResponseHeaderTimeout
+	}
+	if c.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = c.IdleConnTimeout
+	}
+	if c.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = c.MaxIdleConnsPerHost
+	}
+	if c.InsecureSkipVerify {
+		transport.TLSClientConfig.InsecureSkipVerify = true
+	}
+	cfg.Transport = transport
+
+	return cfg, nil
+}
+
+// SetupFromConfig validates the config and sets up the client with the options
+func SetupFromConfig(c Config, opts ...ClientOption) error {
+	cfg, err := c.ClientConfig()
+	if err != nil {
+		return err
+	}
+	return Setup(cfg, opts...)
+}
+
+// readCACert reads a PEM CA certificate and checks it can be used
+func readCACert(path string) ([]byte, error) {
+	cert, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ca_cert: %s", err)
+	}
+	if !x509.NewCertPool().AppendCertsFromPEM(cert) {
+		return nil, fmt.Errorf("ca_cert: no valid PEM certificate in %s", path)
+	}
+	return cert, nil
+}
@@ -0,0 +1,161 @@
+package elastic
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "es.yml")
+	yml := "addresses: [\"http://es1:9200\"]\nusername: elastic\npassword: secret\ndial_timeout: 5s\nmax_retries: 2\n"
+	if err := os.WriteFile(path, []byte(yml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// env overrides the file
+	t.Setenv("ES_ADDRESSES", "http://es2:9200, http://es3:9200,")
+	t.Setenv("ES_MAX_RETRIES", "5")
+	t.Setenv("ES_COMPRESS_REQUEST_BODY", "true")
+
+	c, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c.Addresses, []string{"http://es2:9200", "http://es3:9200"}) {
+		t.Errorf("addresses %v", c.Addresses)
+	}
+	if c.Username != "elastic" || c.Password != "secret" || c.DialTimeout != 5*time.Second {
+		t.Errorf("file values not kept: %+v", c)
+	}
+	if c.MaxRetries != 5 || !c.CompressRequestBody {
+		t.Errorf("env values not applied: %+v", c)
+	}
+
+	t.Setenv("ES_DIAL_TIMEOUT", "soon")
+	if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), "ES_DIAL_TIMEOUT") {
+		t.Errorf("got %v", err)
+	}
+
+	if _, err := LoadConfig(filepath.Join(t.TempDir(), "none.yml")); err == nil {
+		t.Error("no error for a missing file")
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	ca := writeCA(t)
+	fingerprint := strings.Repeat("ab", 32)
+
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr bool
+	}{
+		{"addresses", Config{Addresses: []string{"https://es:9200"}}, false},
+		{"cloud id", Config{CloudID: "name:abc"}, false},
+		{"no address", Config{}, true},
+		{"addresses and cloud id", Config{Addresses: []string{"http://es:9200"}, CloudID: "name:abc"}, true},
+		{"no scheme", Config{Addresses: []string{"es:9200"}}, true},
+		{"api key and user", Config{CloudID: "name:abc", APIKey: "key", Username: "elastic"}, true},
+		{"password without user", Config{CloudID: "name:abc", Password: "secret"}, true},
+		{"ca", Config{CloudID: "name:abc", CACertPath: ca}, false},
+		{"missing ca", Config{CloudID: "name:abc", CACertPath: filepath.Join(t.TempDir(), "none.pem")}, true},
+		{"fingerprint", Config{CloudID: "name:abc", CertificateFingerprint: fingerprint}, false},
+		{"short fingerprint", Config{CloudID: "name:abc", CertificateFingerprint: "abcd"}, true},
+		{"insecure with ca", Config{CloudID: "name:abc", CACertPath: ca, InsecureSkipVerify: true}, true},
+		{"negative duration", Config{CloudID: "name:abc", DialTimeout: -time.Second}, true},
+	}
+
+	for _, test := range tests {
+		if err := test.config.Validate(); (err != nil) != test.wantErr {
+			t.Errorf("%s: got %v", test.name, err)
+		}
+	}
+}
+
+func TestClientConfig(t *testing.T) {
+	c := Config{
+		Addresses:           []string{"https://es:9200"},
+		CACertPath:          writeCA(t),
+		TLSHandshakeTimeout: 3 * time.Second,
+		MaxIdleConnsPerHost: 20,
+	}
+
+	cfg, err := c.ClientConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.CACert) == 0 {
+		t.Error("ca cert not read")
+	}
+	transport, ok := cfg.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("transport %T", cfg.Transport)
+	}
+	if transport.TLSHandshakeTimeout != 3*time.Second || transport.MaxIdleConnsPerHost != 20 {
+		t.Errorf("transport timeouts not set")
+	}
+	if transport == http.DefaultTransport {
+		t.Error("default transport modified")
+	}
+
+	if _, err := (Config{}).ClientConfig(); err == nil {
+		t.Error("no error for an invalid config")
+	}
+}
+
+func TestSetupFromConfigOptions(t *testing.T) {
+	previous := esTransport.current()
+	t.Cleanup(func() {
+		esTransport.state.Store(previous)
+	})
+
+	err := SetupFromConfig(Config{Addresses: []string{"http://localhost:9200"}},
+		WithRateLimit(RateLimit{Read: Limits{MaxInFlight: 1}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if esTransport.current().limiter == nil {
+		t.Error("options not passed to the client")
+	}
+}
+
+// writeCA writes a self-signed CA certificate and returns its path
+func writeCA(t *testing.T) string {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
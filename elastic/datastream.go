@@ -5,11 +5,15 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"time"
 
 	"github.com/elastic/go-elasticsearch/v8/esapi"
 )
 
-func DataStreamSaveDoc(alias string, d Doc) error {
+func DataStreamSaveDoc(alias string, d Doc, opts ...WriteOption) error {
+
+	o := applyWriteOptions(opts)
 
 	body, err := json.Marshal(d); if err != nil {
 		return err
@@ -17,15 +21,16 @@ func DataStreamSaveDoc(alias string, d Doc) error {
 
 	// Set up the request object.
 	req := esapi.IndexRequest{
-		Index:   alias,
-		Body:    bytes.NewReader(body),
-		Refresh: "true",
+		Index:    alias,
+		Body:     bytes.NewReader(body),
+		Refresh:  "true",
+		Pipeline: o.pipeline,
 	}
 
 	// Perform the request with the client.
-	res, err := req.Do(context.Background(), es)
+	res, err := req.Do(context.Background(), esTransport)
 	if err != nil {
-		return fmt.Errorf("es error getting response: %s", err)
+		return fmt.Errorf("es error getting response: %w", err)
 	}
 	
 	// Securely close Body
@@ -39,4 +44,43 @@ func DataStreamSaveDoc(alias string, d Doc) error {
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+// isDataStream tells if name is a data stream
+func isDataStream(name string, timeOut int) (bool, error) {
+
+	// Set up a context with a timeout.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOut)*time.Second)
+	defer cancel()
+
+	// Set up the request object.
+	req := esapi.IndicesGetDataStreamRequest{
+		Name: []string{name},
+	}
+
+	res, err := req.Do(ctx, esTransport)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return false, fmt.Errorf("isDataStream - request timed out")
+		}
+		return false, fmt.Errorf("es error getting response: %w", err)
+	}
+
+	// Securely close Body
+	if res.Body != nil {
+		defer res.Body.Close()
+	}
+
+	if res.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+
+	//  deserialize response and possible errors
+	r, err := getResponseMap(res)
+	if err != nil {
+		return false, err
+	}
+
+	streams, _ := r["data_streams"].([]interface{})
+	return len(streams) > 0, nil
+}
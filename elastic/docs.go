@@ -5,6 +5,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/elastic/go-elasticsearch/v8/esapi"
@@ -12,17 +15,56 @@ import (
 
 // returns a slice of map containing one doc
 // a response in a form of a slice is needed to apply esdto.ToPosts
+// served from the docs cache when enabled (see EnableDocCache)
 func GetDocById(index string, id string, source []string, timeOut int) (map[string]interface{}, error) {
+	c := getCache()
+	if c == nil {
+		return getDocById(index, id, source, timeOut)
+	}
+
+	key := cacheKey(index, id, source)
+	if doc, ok := c.get(key); ok {
+		return doc, nil
+	}
+
+	// concurrent misses of the same doc share one request
+	v, err, _ := c.group.Do(key, func() (interface{}, error) {
+		token := c.begin(index, id)
+		defer c.end(token)
+
+		doc, concrete, err := getDoc(index, id, source, timeOut)
+		if err != nil {
+			return doc, err
+		}
+		c.set(index, id, concrete, source, doc, token)
+		return doc, nil
+	})
+	doc, _ := v.(map[string]interface{})
+	if err != nil {
+		return doc, err
+	}
+
+	return copyDoc(doc), nil
+}
+
+// getDocById gets a doc without cache
+func getDocById(index string, id string, source []string, timeOut int) (map[string]interface{}, error) {
+	doc, _, err := getDoc(index, id, source, timeOut)
+	return doc, err
+}
+
+// getDoc gets a doc without cache, and the concrete index it was read from
+func getDoc(index string, id string, source []string, timeOut int) (map[string]interface{}, string, error) {
 
 	doc := make(map[string]interface{})
 	// CHECKS
 	exists, err := IndexExists(index)
 	if err != nil {
-		return doc, err
+		return doc, "", err
 	}
 
 	if !exists {
-		return doc, fmt.Errorf("no index with name '%s'", index)
+		return doc, "", fmt.Errorf("no index with name '%s'", index)
 	}
 
 	// Set up the request object.
@@ -37,12 +79,12 @@ func GetDocById(index string, id string, source []string, timeOut int) (map[stri
 	defer cancel()
 
 	// Perform the request with the client.
-	res, err := req.Do(ctx, es)
+	res, err := req.Do(ctx, esTransport)
 	if err != nil {
 		if ctx.Err() == context.DeadlineExceeded {
-			return doc, fmt.Errorf("getDocById - request timed out")
+			return doc, "", fmt.Errorf("getDocById - request timed out")
 		}
-		return doc, fmt.Errorf("es error getting response: %s", err)
+		return doc, "", fmt.Errorf("es error getting response: %w", err)
 	}
 
 	// Securely close Body
@@ -53,18 +95,22 @@ func GetDocById(index string, id string, source []string, timeOut int) (map[stri
 	//  deserialize response and possible errors
 	r, err := getResponseMap(res)
 	if err != nil {
-		return doc, err
+		return doc, "", err
 	}
 
 	if r["found"].(bool) {
 		doc["id"] = r["_id"].(string)
 		doc["source"] = r["_source"].(map[string]interface{})
 	}
+	concrete, _ := r["_index"].(string)
 
-	return doc, nil
+	return doc, concrete, nil
 }
 
 // returns a list of docs providing a list of ids and a source set
+// missing docs and docs failing to be read are returned with a nil source,
+// use GetDocs to get per doc status and errors
+// cached docs are served from the docs cache when enabled
 func GetDocsMultiIds(index string, ids []string, source []string, timeOut int) ([]map[string]interface{}, error) {
 	var docs []map[string]interface{}
 
@@ -78,70 +124,32 @@ func GetDocsMultiIds(index string, ids []string, source []string, timeOut int) (
 		return docs, fmt.Errorf("no index with name '%s'", index)
 	}
 
-	// build body
-	type docBody struct {
-		Id     string   `json:"_id"`
-		Source []string `json:"_source"`
+	refs := make([]DocRef, len(ids))
+	for i, id := range ids {
+		refs[i] = DocRef{Index: index, Id: id}
 	}
 
-	var docsBody []docBody
-	for _, id := range ids {
-		d := docBody{
-			Id:     id,
-			Source: source,
-		}
-		docsBody = append(docsBody, d)
-	}
-
-	// Build the request body.
-	body, err := json.Marshal(docsBody)
+	results, err := GetDocs(refs, source, timeOut)
 	if err != nil {
 		return docs, err
 	}
 
-	// Set up the request object.
-	req := esapi.MgetRequest{
-		Index: index,
-		Body:  bytes.NewReader([]byte(fmt.Sprintf(`{"docs":%s}`, body))),
-	}
-
-	// Set up a context with a timeout.
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOut)*time.Second)
-	defer cancel()
-
-	// Perform the request with the client.
-	res, err := req.Do(ctx, es)
-	if err != nil {
-		if ctx.Err() == context.DeadlineExceeded {
-			return docs, fmt.Errorf("GetDocsMultiIds - request timed out")
-		}
-		return docs, fmt.Errorf("es error getting response: %s", err)
-	}
-	
-	// Securely close Body
-	if res.Body != nil {
-		defer res.Body.Close()
-	}
-
-	//  deserialize response and possible errors
-	r, err := getResponseMap(res)
-	if err != nil {
-		return docs, err
-	}
-
-	for _, doc := range r["docs"].([]interface{}) {
-		source := doc.(map[string]interface{})["_source"]
-		id := doc.(map[string]interface{})["_id"].(string)
+	for _, result := range results {
 		m := make(map[string]interface{})
-		m["id"] = id
-		m["source"] = source
+		m["id"] = result.Id
+		m["source"] = nil
+		if result.Status == DocFound {
+			m["source"] = result.Source
+		}
 		docs = append(docs, m)
 	}
 
 	return docs, nil
 }
 
-func SaveDoc(index string, d Doc, timeOut int) (string,error) {
+func SaveDoc(index string, d Doc, timeOut int, opts ...WriteOption) (string,error) {
+
+	o := applyWriteOptions(opts)
 
 	// CHECKS
 	exists, err := IndexExists(index)
@@ -164,18 +172,19 @@ func SaveDoc(index string, d Doc, timeOut int) (string,error) {
 
 	// Set up the request object.
 	req := esapi.IndexRequest{
-		Index:   index,
-		Body:    bytes.NewReader(body),
-		Refresh: "true",
+		Index:    index,
+		Body:     bytes.NewReader(body),
+		Refresh:  "true",
+		Pipeline: o.pipeline,
 	}
 
 	// Perform the request with the client.
-	res, err := req.Do(ctx, es)
+	res, err := req.Do(ctx, esTransport)
 	if err != nil {
 		if ctx.Err() == context.DeadlineExceeded {
 			return "", fmt.Errorf("SaveDoc - request timed out")
 		}
-		return "", fmt.Errorf("es error getting response: %s", err)
+		return "", fmt.Errorf("es error getting response: %w", err)
 	}
 	
 	// Securely close Body
@@ -194,6 +203,7 @@ func SaveDoc(index string, d Doc, timeOut int) (string,error) {
         return "", fmt.Errorf("could not get document ID from response")
     }
 
+	InvalidateDoc(index, id)
 
 	return id, nil
 }
@@ -230,12 +240,12 @@ func UpdateDoc(index string, id string, d Doc, timeOut int) (string, string, err
 	defer cancel()
 
 	// Perform the request with the client.
-	res, err := req.Do(ctx, es)
+	res, err := req.Do(ctx, esTransport)
 	if err != nil {
 		if ctx.Err() == context.DeadlineExceeded {
 			return id, result, fmt.Errorf("request timed out")
 		}
-		return id, result, fmt.Errorf("es error getting response: %s", err)
+		return id, result, fmt.Errorf("es error getting response: %w", err)
 	}
 	
 	// Securely close Body
@@ -243,6 +253,9 @@ func UpdateDoc(index string, id string, d Doc, timeOut int) (string, string, err
 		defer res.Body.Close()
 	}
 
+	// the doc may have changed even on error
+	InvalidateDoc(index, id)
+
 	//  deserialize response and possible errors
 	r, err := getResponseMap(res)
 	if err != nil {
@@ -257,9 +270,10 @@ func UpdateDoc(index string, id string, d Doc, timeOut int) (string, string, err
 
 // based on update_by_query
 // returns count of updated docs
-func UpdateByQuery(index string, query map[string]interface{}, timeOut int) (int, error) {
+func UpdateByQuery(index string, query map[string]interface{}, timeOut int, opts ...WriteOption) (int, error) {
 
 	var updatedCount int
+	o := applyWriteOptions(opts)
 
 	// CHECKS
 	exists, err := IndexExists(index)
@@ -279,8 +293,9 @@ func UpdateByQuery(index string, query map[string]interface{}, timeOut int) (int
 
 	// Set up the request object.
 	req := esapi.UpdateByQueryRequest{
-		Index: []string{index},
-		Body:  bytes.NewReader(body),
+		Index:    []string{index},
+		Body:     bytes.NewReader(body),
+		Pipeline: o.pipeline,
 	}
 
 	// Set up a context with a timeout.
@@ -288,12 +303,12 @@ func UpdateByQuery(index string, query map[string]interface{}, timeOut int) (int
 	defer cancel()
 
 	// Perform the request with the client.
-	res, err := req.Do(ctx, es)
+	res, err := req.Do(ctx, esTransport)
 	if err != nil {
 		if ctx.Err() == context.DeadlineExceeded {
 			return updatedCount, fmt.Errorf("UpdateDoc - request timed out")
 		}
-		return updatedCount, fmt.Errorf("es error getting response: %s", err)
+		return updatedCount, fmt.Errorf("es error getting response: %w", err)
 	}
 	
 	// Securely close Body
@@ -301,6 +316,9 @@ func UpdateByQuery(index string, query map[string]interface{}, timeOut int) (int
 		defer res.Body.Close()
 	}
 
+	// docs may have changed even on error
+	InvalidateIndex(index)
+
 	//  deserialize response and possible errors
 	r, err := getResponseMap(res)
 	if err != nil {
@@ -312,16 +330,28 @@ func UpdateByQuery(index string, query map[string]interface{}, timeOut int) (int
 	return updatedCount, nil
 }
 
-func DeleteDoc(index string, id string, timeOut int) error {
+// result of DeleteDoc
+type DeleteResult string
+
+const (
+	DeleteDeleted  DeleteResult = "deleted"
+	DeleteNotFound DeleteResult = "not_found"
+)
+
+// DeleteDoc deletes a doc by id
+// a missing doc is not an error, the result is DeleteNotFound
+func DeleteDoc(index string, id string, timeOut int) (DeleteResult, error) {
+
+	var result DeleteResult
 
 	// CHECKS
 	exists, err := IndexExists(index)
 	if err != nil {
-		return err
+		return result, err
 	}
 
 	if !exists {
-		return fmt.Errorf("no index with name '%s'", index)
+		return result, fmt.Errorf("no index with name '%s'", index)
 	}
 
 	// Set up the request object.
@@ -335,36 +365,110 @@ func DeleteDoc(index string, id string, timeOut int) error {
 	defer cancel()
 
 	// Perform the request with the client.
-	res, err := req.Do(ctx, es)
+	res, err := req.Do(ctx, esTransport)
 	if err != nil {
 		if ctx.Err() == context.DeadlineExceeded {
-			return fmt.Errorf("request timed out")
+			return result, fmt.Errorf("request timed out")
 		}
-		return fmt.Errorf("es error getting response: %s", err)
+		return result, fmt.Errorf("es error getting response: %w", err)
 	}
-	
+
 	// Securely close Body
 	if res.Body != nil {
 		defer res.Body.Close()
 	}
 
+	// the doc may have changed even on error
+	InvalidateDoc(index, id)
+
+	// a missing doc is answered with a 404 and a not_found result
+	if res.StatusCode == http.StatusNotFound {
+		var r map[string]interface{}
+		if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+			return result, fmt.Errorf("error parsing the response body: %s", err)
+		}
+		if r["result"] == string(DeleteNotFound) {
+			return DeleteNotFound, nil
+		}
+		return result, fmt.Errorf("[%s] %s", res.Status(), errorReason(r["error"]))
+	}
+
 	//  deserialize response and possible errors
 	r, err := getResponseMap(res)
 	if err != nil {
-		return err
+		return result, err
+	}
+
+	s, ok := r["result"].(string)
+	if !ok {
+		return result, fmt.Errorf("could not get delete result from response")
 	}
+	result = DeleteResult(s)
 
-	result := r["result"].(string)
+	return result, nil
+}
+
+// DeleteByQueryOption configures DeleteByQuery
+type DeleteByQueryOption func(*deleteByQueryOptions)
+
+type deleteByQueryOptions struct {
+	dryRun        bool
+	allowMatchAll bool
+	maxDocs       int
+}
 
-	fmt.Printf("Doc id=%v in index=%v result query=%v", id, index, result)
+// WithDryRun deletes nothing, DeleteByQuery returns the count of docs that would be deleted
+func WithDryRun() DeleteByQueryOption {
+	return func(o *deleteByQueryOptions) {
+		o.dryRun = true
+	}
+}
+
+// WithAllowMatchAll allows a query matching all docs of the index
+func WithAllowMatchAll() DeleteByQueryOption {
+	return func(o *deleteByQueryOptions) {
+		o.allowMatchAll = true
+	}
+}
 
-	return nil
+// WithMaxDocs deletes at most n docs
+func WithMaxDocs(n int) DeleteByQueryOption {
+	return func(o *deleteByQueryOptions) {
+		o.maxDocs = n
+	}
 }
 
-func DeleteByQuery(index string, query map[string]interface{}, timeOut int) (int, error) {
+// DeleteByQuery deletes docs matching the query (a search body)
+// a query matching all docs is rejected unless WithAllowMatchAll is given
+// returns count of deleted docs (or of docs that would be deleted with WithDryRun)
+func DeleteByQuery(index string, query map[string]interface{}, timeOut int, opts ...DeleteByQueryOption) (int, error) {
 	var deletedCount int
 
+	var o deleteByQueryOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	// CHECKS
+	if !o.allowMatchAll && isMatchAll(query["query"]) {
+		return deletedCount, fmt.Errorf("delete by query on '%s' matches all docs, use WithAllowMatchAll to allow it", index)
+	}
+	if o.maxDocs < 0 {
+		return deletedCount, fmt.Errorf("max docs cannot be negative")
+	}
+
+	// Count checks the index exists
+	if o.dryRun {
+		count, err := Count([]string{index}, query, timeOut)
+		if err != nil {
+			return deletedCount, err
+		}
+		if o.maxDocs > 0 && count > o.maxDocs {
+			count = o.maxDocs
+		}
+		return count, nil
+	}
+
 	exists, err := IndexExists(index)
 	if err != nil {
 		return deletedCount, err
@@ -385,25 +489,31 @@ func DeleteByQuery(index string, query map[string]interface{}, timeOut int) (int
 		Index: []string{index},
 		Body:  bytes.NewReader(body),
 	}
+	if o.maxDocs > 0 {
+		req.MaxDocs = &o.maxDocs
+	}
 
 	// Set up a context with a timeout.
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOut)*time.Second)
 	defer cancel()
 
 	// Perform the request with the client.
-	res, err := req.Do(ctx, es)
+	res, err := req.Do(ctx, esTransport)
 	if err != nil {
 		if ctx.Err() == context.DeadlineExceeded {
 			return deletedCount, fmt.Errorf("request timed out")
 		}
-		return deletedCount, fmt.Errorf("es error getting response: %s", err)
+		return deletedCount, fmt.Errorf("es error getting response: %w", err)
 	}
-	
+
 	// Securely close Body
 	if res.Body != nil {
 		defer res.Body.Close()
 	}
 
+	// docs may have changed even on error
+	InvalidateIndex(index)
+
 	//  deserialize response and possible errors
 	r, err := getResponseMap(res)
 	if err != nil {
@@ -416,3 +526,127 @@ func DeleteByQuery(index string, query map[string]interface{}, timeOut int) (int
 
 	return deletedCount, nil
 }
+
+// isMatchAll tells if a query clause matches all docs:
+// no query, match_all, or a bool query without restricting clauses
+// the query can be of any type marshaling to a query object
+func isMatchAll(query interface{}) bool {
+	if query == nil {
+		return true
+	}
+
+	// typed queries are inspected as plain JSON
+	body, err := json.Marshal(query)
+	if err != nil {
+		// the request cannot be sent either
+		return false
+	}
+	var q interface{}
+	if err := json.Unmarshal(body, &q); err != nil {
+		return false
+	}
+
+	return matchesAll(q)
+}
+
+// matchesAll tells if a decoded query clause matches all docs
+func matchesAll(query interface{}) bool {
+	if query == nil {
+		return true
+	}
+	q, ok := query.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	if len(q) == 0 {
+		return true
+	}
+
+	if _, ok := q["match_all"]; ok {
+		return true
+	}
+
+	b, ok := q["bool"].(map[string]interface{})
+	if !ok || len(q) > 1 {
+		return false
+	}
+
+	// must_not restricts the matching docs
+	if clauses := boolClauses(b["must_not"]); len(clauses) > 0 {
+		return false
+	}
+	required := false
+	for _, key := range []string{"must", "filter"} {
+		for _, clause := range boolClauses(b[key]) {
+			required = true
+			if !matchesAll(clause) {
+				return false
+			}
+		}
+	}
+
+	// should restricts unless enough of its clauses match all docs:
+	// minimum_should_match of them, default 1 without must and filter clauses, 0 otherwise
+	should := boolClauses(b["should"])
+	if len(should) == 0 {
+		return true
+	}
+	minimum := 0
+	if !required {
+		minimum = 1
+	}
+	if v, ok := b["minimum_should_match"]; ok {
+		minimum = minimumShouldMatch(v, len(should), required)
+	}
+
+	matching := 0
+	for _, clause := range should {
+		if matchesAll(clause) {
+			matching++
+		}
+	}
+
+	return matching >= minimum
+}
+
+// minimumShouldMatch returns the count of should clauses that must match
+// integers and percentages are supported, other forms count as 1 so that a match_all clause is enough
+// at least one clause must match without must and filter clauses
+func minimumShouldMatch(v interface{}, clauses int, required bool) int {
+	minimum := 1
+	switch m := v.(type) {
+	case float64:
+		minimum = int(m)
+	case string:
+		if strings.HasSuffix(m, "%") {
+			if p, err := strconv.Atoi(strings.TrimSuffix(m, "%")); err == nil {
+				minimum = clauses * p / 100
+				if p < 0 {
+					minimum = clauses + minimum
+				}
+			}
+		} else if n, err := strconv.Atoi(m); err == nil {
+			minimum = n
+		}
+	}
+	if minimum < 0 {
+		minimum = clauses + minimum
+	}
+
+	if minimum < 1 && !required {
+		minimum = 1
+	}
+	return minimum
+}
+
+// boolClauses returns clauses of a bool query part, given as an object or an array
+func boolClauses(v interface{}) []interface{} {
+	switch c := v.(type) {
+	case []interface{}:
+		return c
+	case map[string]interface{}:
+		return []interface{}{c}
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,79 @@
+package elastic
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestIsMatchAll(t *testing.T) {
+	type term struct {
+		Term map[string]string `json:"term"`
+	}
+
+	tests := []struct {
+		name  string
+		query interface{}
+		want  bool
+	}{
+		{"no query", nil, true},
+		{"empty", map[string]interface{}{}, true},
+		{"match all", map[string]interface{}{"match_all": map[string]interface{}{}}, true},
+		{"term", map[string]interface{}{"term": map[string]interface{}{"a": 1}}, false},
+		{"typed map", map[string]map[string]interface{}{"term": {"a": 1}}, false},
+		{"typed match all", map[string]map[string]interface{}{"match_all": {}}, true},
+		{"struct", term{Term: map[string]string{"a": "b"}}, false},
+		{"bool filter", map[string]interface{}{"bool": map[string]interface{}{"filter": []map[string]interface{}{{"term": map[string]interface{}{"a": 1}}}}}, false},
+		{"bool must match all", map[string]interface{}{"bool": map[string]interface{}{"must": map[string]interface{}{"match_all": map[string]interface{}{}}}}, true},
+		{"bool must not", map[string]interface{}{"bool": map[string]interface{}{"must_not": []interface{}{map[string]interface{}{"term": map[string]interface{}{"a": 1}}}}}, false},
+		{"bool should match all", map[string]interface{}{"bool": map[string]interface{}{"should": []interface{}{map[string]interface{}{"match_all": map[string]interface{}{}}}}}, true},
+		{"bool should term", map[string]interface{}{"bool": map[string]interface{}{"should": []interface{}{map[string]interface{}{"term": map[string]interface{}{"a": 1}}}}}, false},
+		{"bool optional should", map[string]interface{}{"bool": map[string]interface{}{
+			"filter": map[string]interface{}{"match_all": map[string]interface{}{}},
+			"should": []interface{}{map[string]interface{}{"term": map[string]interface{}{"a": 1}}},
+		}}, true},
+		{"bool minimum should", map[string]interface{}{"bool": map[string]interface{}{
+			"filter":               map[string]interface{}{"match_all": map[string]interface{}{}},
+			"should":               []interface{}{map[string]interface{}{"term": map[string]interface{}{"a": 1}}},
+			"minimum_should_match": 1,
+		}}, false},
+		{"bool should match all or term", map[string]interface{}{"bool": map[string]interface{}{
+			"should":               []interface{}{map[string]interface{}{"match_all": map[string]interface{}{}}, map[string]interface{}{"term": map[string]interface{}{"a": 1}}},
+			"minimum_should_match": 1,
+		}}, true},
+		{"bool should match all or term, string minimum", map[string]interface{}{"bool": map[string]interface{}{
+			"should":               []interface{}{map[string]interface{}{"match_all": map[string]interface{}{}}, map[string]interface{}{"term": map[string]interface{}{"a": 1}}},
+			"minimum_should_match": "1",
+		}}, true},
+		{"bool should match all and term", map[string]interface{}{"bool": map[string]interface{}{
+			"should":               []interface{}{map[string]interface{}{"match_all": map[string]interface{}{}}, map[string]interface{}{"term": map[string]interface{}{"a": 1}}},
+			"minimum_should_match": 2,
+		}}, false},
+		{"bool should percentage", map[string]interface{}{"bool": map[string]interface{}{
+			"should":               []interface{}{map[string]interface{}{"match_all": map[string]interface{}{}}, map[string]interface{}{"term": map[string]interface{}{"a": 1}}},
+			"minimum_should_match": "50%",
+		}}, true},
+		{"not an object", "a", false},
+	}
+
+	for _, test := range tests {
+		if got := isMatchAll(test.query); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestDeleteByQueryDryRun(t *testing.T) {
+	transport := &fakeTransport{handler: func(req *http.Request) (*http.Response, error) {
+		return jsonResponse(http.StatusOK, `{"count":3}`), nil
+	}}
+	useTransport(t, transport)
+
+	query := map[string]interface{}{"query": map[string]interface{}{"term": map[string]interface{}{"a": 1}}}
+	count, err := DeleteByQuery("posts", query, 5, WithDryRun(), WithMaxDocs(2))
+	if err != nil || count != 2 {
+		t.Fatalf("got %d, %v", count, err)
+	}
+	if n := transport.count(http.MethodHead, "/posts"); n != 1 {
+		t.Errorf("%d existence checks, want 1", n)
+	}
+}
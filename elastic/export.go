@@ -0,0 +1,467 @@
+package elastic
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// default count of docs per search or bulk request of exports and imports
+const defaultExportBatchSize = 1000
+
+// default timeout of each request of exports and imports, in seconds
+const defaultExportTimeOut = 30
+
+// point in time keep alive between two export requests
+const exportKeepAlive = "5m"
+
+// ExportLine is a line of an NDJSON export
+// the source is kept as is, so that numbers are not altered (e.g. integers above 2^53)
+// Index is the data stream when a data stream is exported, not its backing indices
+type ExportLine struct {
+	Index  string          `json:"_index"`
+	Id     string          `json:"_id"`
+	Source json.RawMessage `json:"_source"`
+}
+
+// exportPage is a search response of an export, decoded without altering numbers
+type exportPage struct {
+	PitId string `json:"pit_id"`
+	Hits  struct {
+		Hits []struct {
+			Index  string            `json:"_index"`
+			Id     string            `json:"_id"`
+			Source json.RawMessage   `json:"_source"`
+			Sort   []json.RawMessage `json:"sort"`
+		} `json:"hits"`
+	} `json:"hits"`
+}
+
+// ExportOptions configures an export
+// Query restricts exported docs (all when nil), TimeOut applies to each request, default 30s
+type ExportOptions struct {
+	Query     map[string]interface{}
+	BatchSize int
+	Gzip      bool
+	TimeOut   int
+}
+
+// ImportOptions configures an import
+// Index renames the target index (the index of each line when empty), docs are created in data streams
+// KeepIds preserves docs ids, otherwise elastic generates them
+// Progress is called after each bulk request with the count of imported docs so far
+// TimeOut applies to each request, default 30s
+type ImportOptions struct {
+	Index     string
+	KeepIds   bool
+	BatchSize int
+	Pipeline  string
+	Progress  func(imported int)
+	TimeOut   int
+}
+
+// ImportResult reports an import
+type ImportResult struct {
+	Imported int
+	Failed   []BulkItem
+}
+
+// IndexMeta holds what is needed to recreate an index
+type IndexMeta struct {
+	Mappings map[string]interface{} `json:"mappings"`
+	Settings map[string]interface{} `json:"settings"`
+}
+
+// index settings set by elastic that cannot be given at creation
+var privateIndexSettings = []string{"uuid", "creation_date", "provided_name", "version", "routing", "resize", "history", "hidden"}
+
+// ExportIndex streams all docs of an index (or of an alias, data stream) as NDJSON into w
+// a point in time and search_after keep the export consistent while the index is written to
+// return count of exported docs
+func ExportIndex(w io.Writer, index string, opts ExportOptions) (int, error) {
+
+	var count int
+
+	// CHECKS
+	exists, err := IndexExists(index)
+	if err != nil {
+		return count, err
+	}
+
+	if !exists {
+		return count, fmt.Errorf("no index with name '%s'", index)
+	}
+
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultExportBatchSize
+	}
+	if opts.TimeOut <= 0 {
+		opts.TimeOut = defaultExportTimeOut
+	}
+
+	dataStream, err := isDataStream(index, opts.TimeOut)
+	if err != nil {
+		return count, err
+	}
+
+	var gz *gzip.Writer
+	if opts.Gzip {
+		gz = gzip.NewWriter(w)
+		w = gz
+	}
+	bw := bufio.NewWriter(w)
+	encoder := json.NewEncoder(bw)
+
+	pit, err := openPointInTime(index, opts.TimeOut)
+	if err != nil {
+		return count, err
+	}
+	defer closePointInTime(pit, opts.TimeOut)
+
+	var searchAfter []json.RawMessage
+	for {
+		query := map[string]interface{}{
+			"size": opts.BatchSize,
+			"pit":  map[string]interface{}{"id": pit, "keep_alive": exportKeepAlive},
+			"sort": []interface{}{map[string]interface{}{"_shard_doc": "asc"}},
+		}
+		if opts.Query != nil {
+			query["query"] = opts.Query
+		}
+		if searchAfter != nil {
+			query["search_after"] = searchAfter
+		}
+
+		body, err := json.Marshal(query)
+		if err != nil {
+			return count, err
+		}
+
+		// the index is given by the point in time
+		req := esapi.SearchRequest{
+			Body: bytes.NewReader(body),
+		}
+
+		var page exportPage
+		if err := doRequestDecode(req, "ExportIndex", opts.TimeOut, &page); err != nil {
+			return count, err
+		}
+
+		// the point in time id may change between requests
+		if page.PitId != "" {
+			pit = page.PitId
+		}
+
+		hits := page.Hits.Hits
+		for _, hit := range hits {
+			line := ExportLine{Index: hit.Index, Id: hit.Id, Source: hit.Source}
+			if dataStream {
+				line.Index = index
+			}
+			if err := encoder.Encode(line); err != nil {
+				return count, err
+			}
+			count++
+		}
+
+		if len(hits) < opts.BatchSize {
+			break
+		}
+		searchAfter = hits[len(hits)-1].Sort
+	}
+
+	if err := bw.Flush(); err != nil {
+		return count, err
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return count, err
+		}
+	}
+
+	return count, nil
+}
+
+// ExportIndexToFile exports an index into an NDJSON file, gzipped if opts.Gzip
+// return count of exported docs
+func ExportIndexToFile(path, index string, opts ExportOptions) (int, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := ExportIndex(f, index, opts)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	return count, err
+}
+
+// ImportIndex bulk loads NDJSON docs produced by ExportIndex, gzipped input is detected
+// target indices are not created, use CreateIndexFromMeta first to keep mappings
+func ImportIndex(r io.Reader, opts ImportOptions) (ImportResult, error) {
+
+	var result ImportResult
+
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultExportBatchSize
+	}
+	if opts.TimeOut <= 0 {
+		opts.TimeOut = defaultExportTimeOut
+	}
+
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return result, err
+		}
+		defer gz.Close()
+		br = bufio.NewReader(gz)
+	}
+
+	// data streams only accept create
+	dataStreams := make(map[string]bool)
+	action := func(index string) (string, error) {
+		dataStream, ok := dataStreams[index]
+		if !ok {
+			var err error
+			dataStream, err = isDataStream(index, opts.TimeOut)
+			if err != nil {
+				return "", err
+			}
+			dataStreams[index] = dataStream
+		}
+		if dataStream {
+			return BulkCreate, nil
+		}
+		return BulkIndex, nil
+	}
+
+	var actions []BulkAction
+	flush := func() error {
+		res, err := bulk(actions, false, opts.Pipeline, opts.TimeOut)
+		if err != nil {
+			return err
+		}
+		result.Imported += res.Succeeded
+		result.Failed = append(result.Failed, res.Failed()...)
+		actions = actions[:0]
+		if opts.Progress != nil {
+			opts.Progress(result.Imported)
+		}
+		return nil
+	}
+
+	lineNumber := 0
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			lineNumber++
+
+			var l ExportLine
+			if err := json.Unmarshal(line, &l); err != nil {
+				return result, fmt.Errorf("line %d: %s", lineNumber, err)
+			}
+
+			a := BulkAction{Index: l.Index, Doc: l.Source}
+			if opts.Index != "" {
+				a.Index = opts.Index
+			}
+			if a.Index == "" {
+				return result, fmt.Errorf("line %d: no target index", lineNumber)
+			}
+			op, actionErr := action(a.Index)
+			if actionErr != nil {
+				return result, actionErr
+			}
+			a.Action = op
+			if opts.KeepIds {
+				a.Id = l.Id
+			}
+			actions = append(actions, a)
+
+			if len(actions) >= opts.BatchSize {
+				if err := flush(); err != nil {
+					return result, err
+				}
+			}
+		}
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return result, err
+		}
+	}
+
+	if len(actions) > 0 {
+		if err := flush(); err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// ImportIndexFromFile imports an NDJSON file, gzipped or not
+func ImportIndexFromFile(path string, opts ImportOptions) (ImportResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return ImportResult{}, err
+	}
+	defer f.Close()
+
+	return ImportIndex(f, opts)
+}
+
+// ExportIndexMeta returns mappings and settings of an index
+// settings set by elastic (uuid, creation date...) are removed so the meta can create a new index
+func ExportIndexMeta(index string, timeOut int) (IndexMeta, error) {
+
+	var meta IndexMeta
+
+	// CHECKS
+	exists, err := IndexExists(index)
+	if err != nil {
+		return meta, err
+	}
+
+	if !exists {
+		return meta, fmt.Errorf("no index with name '%s'", index)
+	}
+
+	// Set up the request object.
+	req := esapi.IndicesGetRequest{
+		Index: []string{index},
+	}
+
+	r, err := doRequest(req, "ExportIndexMeta", timeOut)
+	if err != nil {
+		return meta, err
+	}
+
+	// an alias returns its concrete index, a data stream its backing indices, the first one is used
+	for _, v := range r {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		meta.Mappings, _ = m["mappings"].(map[string]interface{})
+		settings, _ := m["settings"].(map[string]interface{})
+		if indexSettings, ok := settings["index"].(map[string]interface{}); ok {
+			for _, key := range privateIndexSettings {
+				delete(indexSettings, key)
+			}
+		}
+		meta.Settings = settings
+		break
+	}
+
+	return meta, nil
+}
+
+// ExportIndexMetaToFile writes mappings and settings of an index into a JSON file
+func ExportIndexMetaToFile(path, index string, timeOut int) error {
+	meta, err := ExportIndexMeta(index, timeOut)
+	if err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, b, 0644)
+}
+
+// CreateIndexFromMeta creates an index from exported mappings and settings
+func CreateIndexFromMeta(index string, meta IndexMeta, timeOut int) error {
+
+	// CHECKS
+	exists, err := IndexExists(index)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		return fmt.Errorf("index '%s' already exists", index)
+	}
+
+	body, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+
+	// Set up the request object.
+	req := esapi.IndicesCreateRequest{
+		Index: index,
+		Body:  bytes.NewReader(body),
+	}
+
+	_, err = doRequest(req, "CreateIndexFromMeta", timeOut)
+	return err
+}
+
+// CreateIndexFromMetaFile creates an index from a file written by ExportIndexMetaToFile
+func CreateIndexFromMetaFile(path, index string, timeOut int) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var meta IndexMeta
+	if err := json.Unmarshal(b, &meta); err != nil {
+		return fmt.Errorf("error parsing %s: %s", path, err)
+	}
+
+	return CreateIndexFromMeta(index, meta, timeOut)
+}
+
+// openPointInTime opens a point in time on an index
+// return its id
+func openPointInTime(index string, timeOut int) (string, error) {
+
+	// Set up the request object.
+	req := esapi.OpenPointInTimeRequest{
+		Index:     strings.Split(index, ","),
+		KeepAlive: exportKeepAlive,
+	}
+
+	r, err := doRequest(req, "openPointInTime", timeOut)
+	if err != nil {
+		return "", err
+	}
+
+	id, ok := r["id"].(string)
+	if !ok {
+		return "", fmt.Errorf("could not get point in time id from response")
+	}
+
+	return id, nil
+}
+
+// closePointInTime releases a point in time, errors are ignored as it expires anyway
+func closePointInTime(id string, timeOut int) {
+	body, err := json.Marshal(map[string]interface{}{"id": id})
+	if err != nil {
+		return
+	}
+
+	// Set up the request object.
+	req := esapi.ClosePointInTimeRequest{
+		Body: bytes.NewReader(body),
+	}
+
+	_, _ = doRequest(req, "closePointInTime", timeOut)
+}
@@ -0,0 +1,58 @@
+package elastic
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestExportImportKeepsNumbers(t *testing.T) {
+	var bulkBody string
+	transport := &fakeTransport{handler: func(req *http.Request) (*http.Response, error) {
+		switch {
+		case req.Method == http.MethodHead:
+			return jsonResponse(http.StatusOK, ``), nil
+		case req.URL.Path == "/_data_stream/logs":
+			return jsonResponse(http.StatusOK, `{"data_streams":[{"name":"logs"}]}`), nil
+		case strings.HasPrefix(req.URL.Path, "/_data_stream/"):
+			return jsonResponse(http.StatusNotFound, `{"error":{"type":"index_not_found_exception","reason":"no such index"}}`), nil
+		case req.URL.Path == "/logs/_pit":
+			return jsonResponse(http.StatusOK, `{"id":"pit-1"}`), nil
+		case req.URL.Path == "/_search":
+			return jsonResponse(http.StatusOK, `{"pit_id":"pit-1","hits":{"hits":[
+				{"_index":".ds-logs-000001","_id":"1","_source":{"user_id":1234567890123456789,"ratio":1.50},"sort":[9007199254740993]}
+			]}}`), nil
+		case req.URL.Path == "/_bulk":
+			b, _ := io.ReadAll(req.Body)
+			bulkBody = string(b)
+			return jsonResponse(http.StatusOK, `{"items":[{"create":{"_index":"logs","_id":"1","status":201,"result":"created"}}]}`), nil
+		}
+		return jsonResponse(http.StatusOK, `{}`), nil
+	}}
+	useTransport(t, transport)
+
+	var buf bytes.Buffer
+	count, err := ExportIndex(&buf, "logs", ExportOptions{}) // default timeout
+	if err != nil || count != 1 {
+		t.Fatalf("ExportIndex: %d, %v", count, err)
+	}
+
+	exported := buf.String()
+	for _, want := range []string{`"_index":"logs"`, `"user_id":1234567890123456789`, `"ratio":1.50`} {
+		if !strings.Contains(exported, want) {
+			t.Errorf("export %s does not contain %s", exported, want)
+		}
+	}
+
+	result, err := ImportIndex(&buf, ImportOptions{KeepIds: true}) // default timeout
+	if err != nil || result.Imported != 1 {
+		t.Fatalf("ImportIndex: %+v, %v", result, err)
+	}
+	for _, want := range []string{`{"create":{"_id":"1","_index":"logs"}}`, `"user_id":1234567890123456789`} {
+		if !strings.Contains(bulkBody, want) {
+			t.Errorf("bulk body %s does not contain %s", bulkBody, want)
+		}
+	}
+}
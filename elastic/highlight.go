@@ -0,0 +1,39 @@
+package elastic
+
+// Highlight configures search highlighting
+// options at the top level apply to every field unless overridden in Fields
+type Highlight struct {
+	Fields            map[string]HighlightField `json:"fields"`
+	PreTags           []string                  `json:"pre_tags,omitempty"`
+	PostTags          []string                  `json:"post_tags,omitempty"`
+	Type              string                    `json:"type,omitempty"` // unified, plain or fvh
+	FragmentSize      int                       `json:"fragment_size,omitempty"`
+	NumberOfFragments *int                      `json:"number_of_fragments,omitempty"` // 0 highlights the whole field
+	NoMatchSize       int                       `json:"no_match_size,omitempty"`
+	Encoder           string                    `json:"encoder,omitempty"` // "html" escapes the text before highlighting
+	RequireFieldMatch *bool                     `json:"require_field_match,omitempty"`
+}
+
+// HighlightField overrides highlighting options for one field
+type HighlightField struct {
+	PreTags           []string               `json:"pre_tags,omitempty"`
+	PostTags          []string               `json:"post_tags,omitempty"`
+	Type              string                 `json:"type,omitempty"`
+	FragmentSize      int                    `json:"fragment_size,omitempty"`
+	NumberOfFragments *int                   `json:"number_of_fragments,omitempty"`
+	NoMatchSize       int                    `json:"no_match_size,omitempty"`
+	HighlightQuery    map[string]interface{} `json:"highlight_query,omitempty"`
+}
+
+// HighlightFields returns a highlight on the given fields wrapping matches with pre and post tags
+func HighlightFields(preTag, postTag string, fields ...string) *Highlight {
+	h := &Highlight{
+		Fields:   make(map[string]HighlightField, len(fields)),
+		PreTags:  []string{preTag},
+		PostTags: []string{postTag},
+	}
+	for _, field := range fields {
+		h.Fields[field] = HighlightField{}
+	}
+	return h
+}
@@ -0,0 +1,17 @@
+package elastic
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestHighlightFields(t *testing.T) {
+	b, err := json.Marshal(HighlightFields("[", "]", "title", "body"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"fields":{"body":{},"title":{}},"pre_tags":["["],"post_tags":["]"]}`
+	if string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+}
@@ -1,7 +1,10 @@
 package elastic
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
 
 	"github.com/elastic/go-elasticsearch/v8/esapi"
 )
@@ -12,7 +15,7 @@ func IndexExists(index string) (bool, error) {
 		Index: []string {index},
 		Pretty: true,
 	}
-	resExistIndex, err := reqExistIndex.Do(context.Background(), es); if err != nil {
+	resExistIndex, err := reqExistIndex.Do(context.Background(), esTransport); if err != nil {
 		return false, err
 	} 
 	
@@ -26,4 +29,122 @@ func IndexExists(index string) (bool, error) {
 	}
 
 	return true, nil
-}
\ No newline at end of file
+}
+
+// ListIndices returns names of indices matching the pattern (all when empty), sorted
+func ListIndices(pattern string, timeOut int) ([]string, error) {
+	var indices []string
+
+	// Set up the request object.
+	req := esapi.CatIndicesRequest{
+		Format: "json",
+		H:      []string{"index"},
+		S:      []string{"index"},
+	}
+	if pattern != "" {
+		req.Index = []string{pattern}
+	}
+
+	l, err := doRequestList(req, "ListIndices", timeOut)
+	if err != nil {
+		return indices, err
+	}
+
+	for _, element := range l {
+		if m, ok := element.(map[string]interface{}); ok {
+			if name, ok := m["index"].(string); ok {
+				indices = append(indices, name)
+			}
+		}
+	}
+
+	return indices, nil
+}
+
+// SwapAlias atomically moves an alias from an index to another
+// with an empty from, the alias is only added to the index
+func SwapAlias(alias, from, to string, timeOut int) error {
+
+	// CHECKS
+	exists, err := IndexExists(to)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return fmt.Errorf("no index with name '%s'", to)
+	}
+
+	var actions []map[string]interface{}
+	if from != "" {
+		actions = append(actions, map[string]interface{}{
+			"remove": map[string]interface{}{"index": from, "alias": alias},
+		})
+	}
+	actions = append(actions, map[string]interface{}{
+		"add": map[string]interface{}{"index": to, "alias": alias},
+	})
+
+	body, err := json.Marshal(map[string]interface{}{"actions": actions})
+	if err != nil {
+		return err
+	}
+
+	// Set up the request object.
+	req := esapi.IndicesUpdateAliasesRequest{
+		Body: bytes.NewReader(body),
+	}
+
+	_, err = doRequest(req, "SwapAlias", timeOut)
+	return err
+}
+
+// Reindex copies docs of source matching the query (all when nil) into dest
+// return count of created and updated docs
+func Reindex(source, dest string, query map[string]interface{}, timeOut int) (int, error) {
+
+	var count int
+
+	// CHECKS
+	exists, err := IndexExists(source)
+	if err != nil {
+		return count, err
+	}
+
+	if !exists {
+		return count, fmt.Errorf("no index with name '%s'", source)
+	}
+
+	src := map[string]interface{}{"index": source}
+	if query != nil {
+		src["query"] = query
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"source": src,
+		"dest":   map[string]interface{}{"index": dest},
+	})
+	if err != nil {
+		return count, err
+	}
+
+	refresh := true
+	// Set up the request object.
+	req := esapi.ReindexRequest{
+		Body:    bytes.NewReader(body),
+		Refresh: &refresh,
+	}
+
+	r, err := doRequest(req, "Reindex", timeOut)
+	if err != nil {
+		return count, err
+	}
+
+	if failures, ok := r["failures"].([]interface{}); ok && len(failures) > 0 {
+		return count, fmt.Errorf("reindex: %d failures, first: %v", len(failures), failures[0])
+	}
+
+	count = toInt(r["created"]) + toInt(r["updated"])
+
+	return count, nil
+}
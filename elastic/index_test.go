@@ -0,0 +1,106 @@
+package elastic
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestListIndices(t *testing.T) {
+	transport := &fakeTransport{handler: func(req *http.Request) (*http.Response, error) {
+		return jsonResponse(http.StatusOK, `[{"index":"logs-1"},{"index":"logs-2"},{"other":"x"}]`), nil
+	}}
+	useTransport(t, transport)
+
+	indices, err := ListIndices("logs-*", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(indices, []string{"logs-1", "logs-2"}) {
+		t.Errorf("got %v", indices)
+	}
+	if n := transport.count(http.MethodGet, "/_cat/indices/logs-*"); n != 1 {
+		t.Errorf("%d cat requests, want 1", n)
+	}
+
+	// errors are objects
+	useTransport(t, &fakeTransport{handler: func(req *http.Request) (*http.Response, error) {
+		return jsonResponse(http.StatusNotFound, `{"error":{"type":"index_not_found_exception","reason":"no such index"},"status":404}`), nil
+	}})
+	if _, err := ListIndices("missing", 5); err == nil {
+		t.Error("no error for a failed request")
+	}
+}
+
+func TestSwapAlias(t *testing.T) {
+	var sent map[string]interface{}
+	useTransport(t, &fakeTransport{handler: func(req *http.Request) (*http.Response, error) {
+		if req.Method == http.MethodHead {
+			return jsonResponse(http.StatusOK, `{}`), nil
+		}
+		b, _ := io.ReadAll(req.Body)
+		json.Unmarshal(b, &sent)
+		return jsonResponse(http.StatusOK, `{"acknowledged":true}`), nil
+	}})
+
+	if err := SwapAlias("posts", "posts-1", "posts-2", 5); err != nil {
+		t.Fatal(err)
+	}
+	actions, _ := sent["actions"].([]interface{})
+	if len(actions) != 2 || actions[0].(map[string]interface{})["remove"] == nil || actions[1].(map[string]interface{})["add"] == nil {
+		t.Errorf("sent %v", sent)
+	}
+
+	if err := SwapAlias("posts", "", "posts-2", 5); err != nil {
+		t.Fatal(err)
+	}
+	if actions, _ := sent["actions"].([]interface{}); len(actions) != 1 {
+		t.Errorf("sent %v", sent)
+	}
+}
+
+func TestReindex(t *testing.T) {
+	response := `{"created":3,"updated":2,"failures":[]}`
+	useTransport(t, &fakeTransport{handler: func(req *http.Request) (*http.Response, error) {
+		if req.Method == http.MethodHead {
+			return jsonResponse(http.StatusOK, `{}`), nil
+		}
+		return jsonResponse(http.StatusOK, response), nil
+	}})
+
+	count, err := Reindex("posts-1", "posts-2", nil, 5)
+	if err != nil || count != 5 {
+		t.Errorf("got %d, %v", count, err)
+	}
+
+	response = `{"created":1,"updated":0,"failures":[{"id":"2","cause":{"type":"mapper_parsing_exception"}}]}`
+	if _, err := Reindex("posts-1", "posts-2", nil, 5); err == nil {
+		t.Error("no error with failures")
+	}
+}
+
+func TestCount(t *testing.T) {
+	var body []byte
+	useTransport(t, &fakeTransport{handler: func(req *http.Request) (*http.Response, error) {
+		body = nil
+		if req.Body != nil {
+			body, _ = io.ReadAll(req.Body)
+		}
+		return jsonResponse(http.StatusOK, `{"count":7}`), nil
+	}})
+
+	// only the query part of the search body is sent
+	count, err := Count([]string{"posts-*"}, map[string]interface{}{"query": map[string]interface{}{"match_all": map[string]interface{}{}}, "size": 10}, 5)
+	if err != nil || count != 7 {
+		t.Fatalf("got %d, %v", count, err)
+	}
+	if string(body) != `{"query":{"match_all":{}}}` {
+		t.Errorf("sent %s", body)
+	}
+
+	if _, err := Count([]string{"posts-*"}, nil, 5); err != nil || body != nil {
+		t.Errorf("sent %s, %v", body, err)
+	}
+}
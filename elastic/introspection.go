@@ -0,0 +1,193 @@
+package elastic
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// FieldCapability describes a field across the requested indices
+// Searchable and Aggregatable are true only when the field is in all the indices where it is mapped
+type FieldCapability struct {
+	Types                  []string // more than one when the mapping conflicts between indices
+	Searchable             bool
+	Aggregatable           bool
+	NonSearchableIndices   []string
+	NonAggregatableIndices []string
+}
+
+// Conflict tells if the field does not have the same type in all indices
+func (f FieldCapability) Conflict() bool {
+	return len(f.Types) > 1
+}
+
+// IndexStatsInfo holds the main stats of an index
+type IndexStatsInfo struct {
+	DocsCount             int64
+	DocsDeleted           int64
+	StoreSizeBytes        int64 // primaries and replicas
+	PrimaryStoreSizeBytes int64
+	SegmentsCount         int64 // primaries and replicas
+}
+
+// CatIndex is a line of the cat indices API
+type CatIndex struct {
+	Index                 string
+	UUID                  string
+	Health                HealthStatus
+	Status                string // open or close
+	Primaries             int
+	Replicas              int
+	DocsCount             int64
+	DocsDeleted           int64
+	StoreSizeBytes        int64
+	PrimaryStoreSizeBytes int64
+}
+
+// FieldCaps returns capabilities of fields (wildcards allowed, all when empty) across indices
+func FieldCaps(indices []string, fields []string, timeOut int) (map[string]FieldCapability, error) {
+
+	caps := make(map[string]FieldCapability)
+
+	if len(fields) == 0 {
+		fields = []string{"*"}
+	}
+
+	// Set up the request object.
+	req := esapi.FieldCapsRequest{
+		Index:  indices,
+		Fields: fields,
+	}
+
+	r, err := doRequest(req, "FieldCaps", timeOut)
+	if err != nil {
+		return caps, err
+	}
+
+	rFields, _ := r["fields"].(map[string]interface{})
+	for name, element := range rFields {
+		types, ok := element.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		c := FieldCapability{Searchable: true, Aggregatable: true}
+		for typ, t := range types {
+			m, _ := t.(map[string]interface{})
+			c.Types = append(c.Types, typ)
+			if searchable, _ := m["searchable"].(bool); !searchable {
+				c.Searchable = false
+			}
+			if aggregatable, _ := m["aggregatable"].(bool); !aggregatable {
+				c.Aggregatable = false
+			}
+			c.NonSearchableIndices = append(c.NonSearchableIndices, toStrings(m["non_searchable_indices"])...)
+			c.NonAggregatableIndices = append(c.NonAggregatableIndices, toStrings(m["non_aggregatable_indices"])...)
+		}
+		// the flags of a type are true when only some of its indices support it
+		if len(c.NonSearchableIndices) > 0 {
+			c.Searchable = false
+		}
+		if len(c.NonAggregatableIndices) > 0 {
+			c.Aggregatable = false
+		}
+		sort.Strings(c.Types)
+
+		caps[name] = c
+	}
+
+	return caps, nil
+}
+
+// IndexStats returns docs, store and segments stats per index (all when indices is empty)
+func IndexStats(indices []string, timeOut int) (map[string]IndexStatsInfo, error) {
+
+	stats := make(map[string]IndexStatsInfo)
+
+	// Set up the request object.
+	req := esapi.IndicesStatsRequest{
+		Index:  indices,
+		Metric: []string{"docs", "store", "segments"},
+	}
+
+	r, err := doRequest(req, "IndexStats", timeOut)
+	if err != nil {
+		return stats, err
+	}
+
+	rIndices, _ := r["indices"].(map[string]interface{})
+	for name, element := range rIndices {
+		m, _ := element.(map[string]interface{})
+		primaries, _ := m["primaries"].(map[string]interface{})
+		total, _ := m["total"].(map[string]interface{})
+
+		docs, _ := primaries["docs"].(map[string]interface{})
+		primaryStore, _ := primaries["store"].(map[string]interface{})
+		store, _ := total["store"].(map[string]interface{})
+		segments, _ := total["segments"].(map[string]interface{})
+
+		stats[name] = IndexStatsInfo{
+			DocsCount:             toInt64(docs["count"]),
+			DocsDeleted:           toInt64(docs["deleted"]),
+			StoreSizeBytes:        toInt64(store["size_in_bytes"]),
+			PrimaryStoreSizeBytes: toInt64(primaryStore["size_in_bytes"]),
+			SegmentsCount:         toInt64(segments["count"]),
+		}
+	}
+
+	return stats, nil
+}
+
+// CatIndices returns indices matching the pattern (all when empty), sorted by name
+func CatIndices(pattern string, timeOut int) ([]CatIndex, error) {
+
+	var indices []CatIndex
+
+	// Set up the request object.
+	req := esapi.CatIndicesRequest{
+		Format: "json",
+		Bytes:  "b",
+		S:      []string{"index"},
+	}
+	if pattern != "" {
+		req.Index = []string{pattern}
+	}
+
+	l, err := doRequestList(req, "CatIndices", timeOut)
+	if err != nil {
+		return indices, err
+	}
+
+	// cat values are strings, and empty for closed indices
+	for _, element := range l {
+		m, ok := element.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		str := func(key string) string {
+			s, _ := m[key].(string)
+			return s
+		}
+		num := func(key string) int64 {
+			n, _ := strconv.ParseInt(strings.TrimSpace(str(key)), 10, 64)
+			return n
+		}
+
+		indices = append(indices, CatIndex{
+			Index:                 str("index"),
+			UUID:                  str("uuid"),
+			Health:                HealthStatus(str("health")),
+			Status:                str("status"),
+			Primaries:             int(num("pri")),
+			Replicas:              int(num("rep")),
+			DocsCount:             num("docs.count"),
+			DocsDeleted:           num("docs.deleted"),
+			StoreSizeBytes:        num("store.size"),
+			PrimaryStoreSizeBytes: num("pri.store.size"),
+		})
+	}
+
+	return indices, nil
+}
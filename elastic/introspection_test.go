@@ -0,0 +1,97 @@
+package elastic
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestFieldCaps(t *testing.T) {
+	useTransport(t, &fakeTransport{handler: func(req *http.Request) (*http.Response, error) {
+		return jsonResponse(http.StatusOK, `{"indices":["logs-1","logs-2"],"fields":{
+			"title":{"text":{"type":"text","searchable":true,"aggregatable":false}},
+			"status":{
+				"keyword":{"type":"keyword","searchable":true,"aggregatable":true,"indices":["logs-1"]},
+				"long":{"type":"long","searchable":true,"aggregatable":true,"indices":["logs-2"]}
+			},
+			"tag":{"keyword":{"type":"keyword","searchable":true,"aggregatable":true,"non_aggregatable_indices":["logs-2"]}}
+		}}`), nil
+	}})
+
+	caps, err := FieldCaps([]string{"logs-*"}, nil, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c := caps["title"]; !c.Searchable || c.Aggregatable || c.Conflict() {
+		t.Errorf("title %+v", c)
+	}
+	if c := caps["status"]; !c.Conflict() || !reflect.DeepEqual(c.Types, []string{"keyword", "long"}) || !c.Aggregatable {
+		t.Errorf("status %+v", c)
+	}
+	// aggregatable only in some indices
+	if c := caps["tag"]; c.Aggregatable || !reflect.DeepEqual(c.NonAggregatableIndices, []string{"logs-2"}) {
+		t.Errorf("tag %+v", c)
+	}
+}
+
+func TestIndexStats(t *testing.T) {
+	useTransport(t, &fakeTransport{handler: func(req *http.Request) (*http.Response, error) {
+		return jsonResponse(http.StatusOK, `{"indices":{"posts":{
+			"primaries":{"docs":{"count":10,"deleted":2},"store":{"size_in_bytes":1000}},
+			"total":{"docs":{"count":20,"deleted":4},"store":{"size_in_bytes":2000},"segments":{"count":6}}
+		}}}`), nil
+	}})
+
+	stats, err := IndexStats([]string{"posts"}, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := IndexStatsInfo{DocsCount: 10, DocsDeleted: 2, StoreSizeBytes: 2000, PrimaryStoreSizeBytes: 1000, SegmentsCount: 6}
+	if len(stats) != 1 || stats["posts"] != want {
+		t.Errorf("got %+v", stats)
+	}
+}
+
+func TestCatIndices(t *testing.T) {
+	transport := &fakeTransport{handler: func(req *http.Request) (*http.Response, error) {
+		return jsonResponse(http.StatusOK, `[
+			{"health":"green","status":"open","index":"posts","uuid":"u1","pri":"1","rep":"1","docs.count":"10","docs.deleted":"2","store.size":"2000","pri.store.size":"1000"},
+			{"health":"red","status":"close","index":"old","uuid":"u2","pri":"2","rep":"0","docs.count":null,"docs.deleted":null,"store.size":null,"pri.store.size":null}
+		]`), nil
+	}}
+	useTransport(t, transport)
+
+	indices, err := CatIndices("", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(indices) != 2 {
+		t.Fatalf("got %+v", indices)
+	}
+	want := CatIndex{Index: "posts", UUID: "u1", Health: StatusGreen, Status: "open", Primaries: 1, Replicas: 1,
+		DocsCount: 10, DocsDeleted: 2, StoreSizeBytes: 2000, PrimaryStoreSizeBytes: 1000}
+	if indices[0] != want {
+		t.Errorf("got %+v", indices[0])
+	}
+	// closed indices have no stats
+	if indices[1].Status != "close" || indices[1].Primaries != 2 || indices[1].DocsCount != 0 {
+		t.Errorf("got %+v", indices[1])
+	}
+
+	if q := transport.requests[0].URL.Query(); q.Get("bytes") != "b" || q.Get("format") != "json" {
+		t.Errorf("query %v", q)
+	}
+	if _, err := CatIndices("posts-*", 5); err != nil {
+		t.Fatal(err)
+	}
+	if n := transport.count(http.MethodGet, "/_cat/indices/posts-*"); n != 1 {
+		t.Errorf("%d pattern requests, want 1", n)
+	}
+}
+
+func TestFieldCapabilityConflict(t *testing.T) {
+	if !(FieldCapability{Types: []string{"keyword", "long"}}).Conflict() || (FieldCapability{Types: []string{"long"}}).Conflict() {
+		t.Error("wrong conflict")
+	}
+}
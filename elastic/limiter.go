@@ -0,0 +1,235 @@
+package elastic
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"golang.org/x/time/rate"
+)
+
+// Limits bounds a class of requests, zero values mean unlimited
+// BytesPerSecond counts request bodies (bulk, index...)
+type Limits struct {
+	RequestsPerSecond float64
+	BytesPerSecond    float64
+	MaxInFlight       int
+}
+
+// RateLimit sets separate budgets for reads (search, get, count...) and writes
+type RateLimit struct {
+	Read  Limits
+	Write Limits
+}
+
+// LimiterStats are the counters of the rate limiter
+type LimiterStats struct {
+	Read  LimitStats
+	Write LimitStats
+}
+
+// LimitStats are the counters of a class of requests
+// Waited is the total time requests spent waiting for the limiter
+type LimitStats struct {
+	Requests uint64
+	Waited   time.Duration
+	InFlight int
+}
+
+// path segments of POST requests that only read
+var readSegments = map[string]bool{
+	"_search": true, "_count": true, "_mget": true, "_msearch": true, "_async_search": true,
+	"_explain": true, "_validate": true, "_field_caps": true, "_analyze": true, "_render": true,
+	"_pit": true, "_termvectors": true, "_mtermvectors": true,
+}
+
+// WithRateLimit limits the requests of the package (token buckets and max in-flight requests)
+// a request waits for its budget, up to its context deadline
+func WithRateLimit(limit RateLimit) ClientOption {
+	return func(o *clientOptions) {
+		o.rateLimit = &limit
+	}
+}
+
+// limiter is an esapi.Transport applying budgets before calling the next transport
+type limiter struct {
+	next  esapi.Transport
+	read  *budget
+	write *budget
+}
+
+// budget limits a class of requests
+type budget struct {
+	requests *rate.Limiter
+	bytes    *rate.Limiter
+	inFlight chan struct{}
+
+	mu    sync.Mutex
+	stats LimitStats
+}
+
+func newLimiter(next esapi.Transport, limit RateLimit) *limiter {
+	l := &limiter{
+		next:  next,
+		read:  newBudget(limit.Read),
+		write: newBudget(limit.Write),
+	}
+	return l
+}
+
+func newBudget(limits Limits) *budget {
+	b := &budget{}
+	if limits.RequestsPerSecond > 0 {
+		burst := int(limits.RequestsPerSecond)
+		if burst < 1 {
+			burst = 1
+		}
+		b.requests = rate.NewLimiter(rate.Limit(limits.RequestsPerSecond), burst)
+	}
+	if limits.BytesPerSecond > 0 {
+		burst := int(limits.BytesPerSecond)
+		if burst < 1 {
+			burst = 1
+		}
+		b.bytes = rate.NewLimiter(rate.Limit(limits.BytesPerSecond), burst)
+	}
+	if limits.MaxInFlight > 0 {
+		b.inFlight = make(chan struct{}, limits.MaxInFlight)
+	}
+	return b
+}
+
+// RateLimiterStats returns the counters of the rate limiter (zero when disabled)
+func RateLimiterStats() LimiterStats {
+	s := esTransport.current()
+	if s == nil || s.limiter == nil {
+		return LimiterStats{}
+	}
+	return LimiterStats{Read: s.limiter.read.snapshot(), Write: s.limiter.write.snapshot()}
+}
+
+// Perform waits for the budget of the request then performs it
+// the in-flight slot is released when the response body is closed
+func (l *limiter) Perform(req *http.Request) (*http.Response, error) {
+	b := l.write
+	if isReadRequest(req) {
+		b = l.read
+	}
+
+	release, err := b.wait(req.Context(), req.ContentLength)
+	if err != nil {
+		return nil, &limiterWaitError{err: err}
+	}
+
+	res, err := l.next.Perform(req)
+	if err != nil || res == nil || res.Body == nil {
+		release()
+		return res, err
+	}
+
+	res.Body = &releaseOnClose{ReadCloser: res.Body, release: release}
+	return res, nil
+}
+
+// wait blocks until the request can be performed
+// return a func releasing the in-flight slot, to be called once
+func (b *budget) wait(ctx context.Context, size int64) (func(), error) {
+	start := time.Now()
+	release := func() {}
+
+	if b.inFlight != nil {
+		select {
+		case b.inFlight <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		var once sync.Once
+		release = func() {
+			once.Do(func() {
+				<-b.inFlight
+			})
+		}
+	}
+
+	if b.requests != nil {
+		if err := b.requests.Wait(ctx); err != nil {
+			release()
+			return nil, err
+		}
+	}
+
+	// a body larger than the burst is taken in several parts
+	if b.bytes != nil {
+		for size > 0 {
+			n := int64(b.bytes.Burst())
+			if size < n {
+				n = size
+			}
+			if err := b.bytes.WaitN(ctx, int(n)); err != nil {
+				release()
+				return nil, err
+			}
+			size -= n
+		}
+	}
+
+	b.mu.Lock()
+	b.stats.Requests++
+	b.stats.Waited += time.Since(start)
+	b.mu.Unlock()
+
+	return release, nil
+}
+
+func (b *budget) snapshot() LimitStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	stats := b.stats
+	stats.InFlight = len(b.inFlight)
+	return stats
+}
+
+// isReadRequest tells if a request only reads
+func isReadRequest(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		return true
+	case http.MethodPost:
+		for _, segment := range strings.Split(req.URL.Path, "/") {
+			if readSegments[segment] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// limiterWaitError is returned when a request could not get its budget before its deadline
+// it is not a failure of the cluster
+type limiterWaitError struct {
+	err error
+}
+
+func (e *limiterWaitError) Error() string {
+	return "rate limiter: " + e.err.Error()
+}
+
+func (e *limiterWaitError) Unwrap() error {
+	return e.err
+}
+
+// releaseOnClose releases an in-flight slot when the body is closed
+type releaseOnClose struct {
+	io.ReadCloser
+	release func()
+}
+
+func (r *releaseOnClose) Close() error {
+	err := r.ReadCloser.Close()
+	r.release()
+	return err
+}
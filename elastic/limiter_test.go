@@ -0,0 +1,127 @@
+package elastic
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestIsReadRequest(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		read   bool
+	}{
+		{http.MethodGet, "/posts/_doc/1", true},
+		{http.MethodHead, "/posts", true},
+		{http.MethodPost, "/posts/_search", true},
+		{http.MethodPost, "/_mget", true},
+		{http.MethodPost, "/posts/_count", true},
+		{http.MethodPost, "/posts/_doc", false},
+		{http.MethodPost, "/_bulk", false},
+		{http.MethodPost, "/posts/_delete_by_query", false},
+		{http.MethodPut, "/posts/_doc/1", false},
+		{http.MethodDelete, "/posts/_doc/1", false},
+	}
+
+	for _, test := range tests {
+		req, _ := http.NewRequest(test.method, "http://localhost:9200"+test.path, nil)
+		if got := isReadRequest(req); got != test.read {
+			t.Errorf("%s %s: read = %v, want %v", test.method, test.path, got, test.read)
+		}
+	}
+}
+
+func TestLimiterReadWriteSplit(t *testing.T) {
+	l := newLimiter(&fakeTransport{}, RateLimit{
+		Read:  Limits{MaxInFlight: 1},
+		Write: Limits{MaxInFlight: 1},
+	})
+
+	// a read holds the only read slot until its body is closed
+	res, err := l.Perform(newRequest(t, http.MethodGet, "/posts/_doc/1", time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// writes have their own budget
+	write, err := l.Perform(newRequest(t, http.MethodPost, "/_bulk", time.Second))
+	if err != nil {
+		t.Fatalf("write blocked by a read: %v", err)
+	}
+	write.Body.Close()
+
+	// a second read waits until its deadline
+	_, err = l.Perform(newRequest(t, http.MethodPost, "/posts/_search", 50*time.Millisecond))
+	var waitErr *limiterWaitError
+	if !errors.As(err, &waitErr) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("second read: got %v, want a limiter wait error", err)
+	}
+
+	if got := l.read.snapshot().InFlight; got != 1 {
+		t.Errorf("read in flight = %d, want 1", got)
+	}
+	if got := l.write.snapshot().Requests; got != 1 {
+		t.Errorf("write requests = %d, want 1", got)
+	}
+	res.Body.Close()
+}
+
+func TestLimiterReleaseOnBodyClose(t *testing.T) {
+	l := newLimiter(&fakeTransport{}, RateLimit{Read: Limits{MaxInFlight: 1}})
+
+	res, err := l.Perform(newRequest(t, http.MethodGet, "/posts/_doc/1", time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := l.read.snapshot().InFlight; got != 1 {
+		t.Fatalf("in flight = %d, want 1", got)
+	}
+
+	// closing twice releases once
+	res.Body.Close()
+	res.Body.Close()
+	if got := l.read.snapshot().InFlight; got != 0 {
+		t.Fatalf("in flight after close = %d, want 0", got)
+	}
+
+	res, err = l.Perform(newRequest(t, http.MethodGet, "/posts/_doc/1", 50*time.Millisecond))
+	if err != nil {
+		t.Fatalf("read after release: %v", err)
+	}
+	res.Body.Close()
+}
+
+func TestLimiterReleaseOnError(t *testing.T) {
+	next := &fakeTransport{handler: func(req *http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	}}
+	l := newLimiter(next, RateLimit{Write: Limits{MaxInFlight: 1}})
+
+	for i := 0; i < 2; i++ {
+		_, err := l.Perform(newRequest(t, http.MethodPost, "/_bulk", 50*time.Millisecond))
+		var waitErr *limiterWaitError
+		if err == nil || errors.As(err, &waitErr) {
+			t.Fatalf("request %d: got %v, want the transport error", i, err)
+		}
+	}
+	if got := l.write.snapshot().InFlight; got != 0 {
+		t.Errorf("in flight = %d, want 0", got)
+	}
+}
+
+// newRequest returns a request with a deadline, canceled at the end of the test
+func newRequest(t *testing.T, method, path string, timeOut time.Duration) *http.Request {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeOut)
+	t.Cleanup(cancel)
+
+	req, err := http.NewRequestWithContext(ctx, method, "http://localhost:9200"+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
@@ -0,0 +1,204 @@
+package elastic
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// docs per mget request and concurrent mget requests of GetDocs
+var (
+	mgetChunkSize   = 1000
+	mgetConcurrency = 4
+)
+
+// status of a doc in GetDocs results
+type DocStatus string
+
+const (
+	DocFound   DocStatus = "found"
+	DocMissing DocStatus = "missing"
+	DocError   DocStatus = "error"
+)
+
+// DocRef references a doc of an index
+type DocRef struct {
+	Index string
+	Id    string
+}
+
+// DocResult is the result of a doc of GetDocs
+// Source is set when found, Error when the doc could not be read (e.g. unknown index)
+type DocResult struct {
+	Index  string
+	Id     string
+	Status DocStatus
+	Source map[string]interface{}
+	Error  string
+
+	concrete string // index the doc was read from, differs from Index for an alias
+}
+
+// GetDocs gets docs of one or several indices, results are in the order of refs
+// large lists are split into several mget requests run concurrently
+// an error is only returned when a request fails, per doc failures are reported in results
+// cached docs are served from the docs cache when enabled
+func GetDocs(refs []DocRef, source []string, timeOut int) ([]DocResult, error) {
+
+	results := make([]DocResult, len(refs))
+
+	// docs to fetch, positions in refs
+	var fetch []int
+	c := getCache()
+	for i, ref := range refs {
+		if c != nil {
+			if doc, ok := c.get(cacheKey(ref.Index, ref.Id, source)); ok {
+				results[i] = cachedDocResult(ref, doc)
+				continue
+			}
+		}
+		fetch = append(fetch, i)
+	}
+
+	// invalidations during the fetch are seen by tokens
+	var tokens []fillToken
+	if c != nil {
+		tokens = make([]fillToken, len(fetch))
+		for i, position := range fetch {
+			tokens[i] = c.begin(refs[position].Index, refs[position].Id)
+		}
+		defer func() {
+			for _, token := range tokens {
+				c.end(token)
+			}
+		}()
+	}
+
+	// split into chunks fetched concurrently
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr error
+	sem := make(chan struct{}, mgetConcurrency)
+
+	for start := 0; start < len(fetch); start += mgetChunkSize {
+		end := start + mgetChunkSize
+		if end > len(fetch) {
+			end = len(fetch)
+		}
+		chunk := fetch[start:end]
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(chunk []int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			chunkRefs := make([]DocRef, len(chunk))
+			for i, position := range chunk {
+				chunkRefs[i] = refs[position]
+			}
+
+			chunkResults, err := mget(chunkRefs, source, timeOut)
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+				return
+			}
+
+			// each goroutine writes its own positions
+			for i, position := range chunk {
+				results[position] = chunkResults[i]
+			}
+		}(chunk)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return results, firstErr
+	}
+
+	if c != nil {
+		for i, position := range fetch {
+			result := results[position]
+			if result.Status == DocFound {
+				c.set(result.Index, result.Id, result.concrete, source, docResultToCache(result), tokens[i])
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// mget performs one mget request, results are in the order of refs
+func mget(refs []DocRef, source []string, timeOut int) ([]DocResult, error) {
+
+	results := make([]DocResult, len(refs))
+
+	type docBody struct {
+		Index  string   `json:"_index"`
+		Id     string   `json:"_id"`
+		Source []string `json:"_source,omitempty"`
+	}
+
+	docsBody := make([]docBody, len(refs))
+	for i, ref := range refs {
+		docsBody[i] = docBody{Index: ref.Index, Id: ref.Id, Source: source}
+	}
+
+	// Build the request body.
+	body, err := json.Marshal(map[string]interface{}{"docs": docsBody})
+	if err != nil {
+		return results, err
+	}
+
+	// Set up the request object.
+	req := esapi.MgetRequest{
+		Body: bytes.NewReader(body),
+	}
+
+	r, err := doRequest(req, "GetDocs", timeOut)
+	if err != nil {
+		return results, err
+	}
+
+	docs, _ := r["docs"].([]interface{})
+	if len(docs) != len(refs) {
+		return results, fmt.Errorf("mget returned %d docs for %d ids", len(docs), len(refs))
+	}
+
+	for i, element := range docs {
+		doc, _ := element.(map[string]interface{})
+
+		result := DocResult{Index: refs[i].Index, Id: refs[i].Id}
+		result.concrete, _ = doc["_index"].(string)
+		if e := errorReason(doc["error"]); e != "" {
+			result.Status = DocError
+			result.Error = e
+		} else if found, _ := doc["found"].(bool); found {
+			result.Status = DocFound
+			result.Source, _ = doc["_source"].(map[string]interface{})
+		} else {
+			result.Status = DocMissing
+		}
+		results[i] = result
+	}
+
+	return results, nil
+}
+
+// docResultToCache converts a found doc into the cached form of GetDocById
+func docResultToCache(result DocResult) map[string]interface{} {
+	return map[string]interface{}{"id": result.Id, "source": result.Source}
+}
+
+// cachedDocResult converts a doc cached by GetDocById or GetDocs
+func cachedDocResult(ref DocRef, doc map[string]interface{}) DocResult {
+	source, _ := doc["source"].(map[string]interface{})
+	return DocResult{Index: ref.Index, Id: ref.Id, Status: DocFound, Source: source}
+}
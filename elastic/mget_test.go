@@ -0,0 +1,36 @@
+package elastic
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestGetDocsMultiIdsPartial(t *testing.T) {
+	useTransport(t, &fakeTransport{handler: func(req *http.Request) (*http.Response, error) {
+		if req.Method == http.MethodHead {
+			return jsonResponse(http.StatusOK, `{}`), nil
+		}
+		return jsonResponse(http.StatusOK, `{"docs":[
+			{"_index":"posts","_id":"1","found":true,"_source":{"title":"a"}},
+			{"_index":"posts","_id":"2","error":{"type":"shard_not_available_exception","reason":"shard unavailable"}},
+			{"_index":"posts","_id":"3","found":false}
+		]}`), nil
+	}})
+
+	// an errored doc does not fail the call, it is returned as a missing one
+	docs, err := GetDocsMultiIds("posts", []string{"1", "2", "3"}, nil, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 3 {
+		t.Fatalf("got %d docs, want 3", len(docs))
+	}
+	if source, _ := docs[0]["source"].(map[string]interface{}); source["title"] != "a" {
+		t.Errorf("found doc: got %v", docs[0])
+	}
+	for _, doc := range docs[1:] {
+		if doc["source"] != nil {
+			t.Errorf("doc %v: got source %v, want nil", doc["id"], doc["source"])
+		}
+	}
+}
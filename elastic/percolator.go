@@ -0,0 +1,177 @@
+package elastic
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// name of the percolator field holding registered queries
+const PercolatorField = "query"
+
+// maximum number of matched queries returned by a percolation
+const maxPercolateMatches = 10000
+
+// CreatePercolatorIndex creates a dedicated index for saved searches
+// properties is the mapping of the percolated documents fields (e.g. the articles index mapping properties),
+// registered queries can only reference fields mapped here
+func CreatePercolatorIndex(index string, properties map[string]interface{}, timeOut int) error {
+
+	// CHECKS
+	exists, err := IndexExists(index)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		return fmt.Errorf("index '%s' already exists", index)
+	}
+
+	props := make(map[string]interface{}, len(properties)+1)
+	for k, v := range properties {
+		props[k] = v
+	}
+	props[PercolatorField] = map[string]interface{}{"type": "percolator"}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"mappings": map[string]interface{}{"properties": props},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Set up the request object.
+	req := esapi.IndicesCreateRequest{
+		Index: index,
+		Body:  bytes.NewReader(body),
+	}
+
+	_, err = doRequest(req, "CreatePercolatorIndex", timeOut)
+	return err
+}
+
+// RegisterPercolatorQuery saves (creates or replaces) a query under the given id
+// metadata (e.g. user id) is stored alongside the query and must be mapped in the percolator index
+func RegisterPercolatorQuery(index, id string, query map[string]interface{}, metadata map[string]interface{}, timeOut int) error {
+
+	// CHECKS
+	if id == "" || query == nil {
+		return fmt.Errorf("percolator query id and query are required")
+	}
+
+	d := make(map[string]interface{}, len(metadata)+1)
+	for k, v := range metadata {
+		d[k] = v
+	}
+	d[PercolatorField] = query
+
+	body, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+
+	// Set up the request object.
+	req := esapi.IndexRequest{
+		Index:      index,
+		DocumentID: id,
+		Body:       bytes.NewReader(body),
+		Refresh:    "true",
+	}
+
+	_, err = doRequest(req, "RegisterPercolatorQuery", timeOut)
+	return err
+}
+
+// DeletePercolatorQuery removes a registered query
+func DeletePercolatorQuery(index, id string, timeOut int) error {
+
+	// Set up the request object.
+	req := esapi.DeleteRequest{
+		Index:      index,
+		DocumentID: id,
+		Refresh:    "true",
+	}
+
+	_, err := doRequest(req, "DeletePercolatorQuery", timeOut)
+	return err
+}
+
+// PercolateDoc returns the ids of registered queries matching the doc
+func PercolateDoc(index string, d Doc, timeOut int) ([]string, error) {
+	matches, err := PercolateDocs(index, []Doc{d}, timeOut)
+	if err != nil {
+		return nil, err
+	}
+	return matches[0], nil
+}
+
+// PercolateDocs returns for each doc (same order) the ids of registered queries matching it
+// the ids can be fed to the alert package to notify users
+func PercolateDocs(index string, docs []Doc, timeOut int) ([][]string, error) {
+
+	matches := make([][]string, len(docs))
+
+	// CHECKS
+	if len(docs) == 0 {
+		return matches, nil
+	}
+
+	exists, err := IndexExists(index)
+	if err != nil {
+		return matches, err
+	}
+
+	if !exists {
+		return matches, fmt.Errorf("no index with name '%s'", index)
+	}
+
+	query := map[string]interface{}{
+		"size":    maxPercolateMatches,
+		"_source": false,
+		"query": map[string]interface{}{
+			"percolate": map[string]interface{}{
+				"field":     PercolatorField,
+				"documents": docs,
+			},
+		},
+	}
+
+	body, err := json.Marshal(query)
+	if err != nil {
+		return matches, err
+	}
+
+	// Set up the request object.
+	req := esapi.SearchRequest{
+		Index:          []string{index},
+		Body:           bytes.NewReader(body),
+		TrackTotalHits: true,
+	}
+
+	r, err := doRequest(req, "PercolateDocs", timeOut)
+	if err != nil {
+		return matches, err
+	}
+
+	hits, total := parseHits(r)
+	if total > len(hits) {
+		return matches, fmt.Errorf("%d queries matched, more than the %d supported", total, maxPercolateMatches)
+	}
+
+	for _, hit := range hits {
+		// slots are the positions of the matching docs, a single doc has slot 0
+		slots := hit.Fields["_percolator_document_slot"]
+		if len(slots) == 0 && len(docs) == 1 {
+			slots = []interface{}{float64(0)}
+		}
+		for _, slot := range slots {
+			if s, ok := slot.(float64); ok && int(s) < len(matches) {
+				matches[int(s)] = append(matches[int(s)], hit.Id)
+			}
+		}
+	}
+
+	return matches, nil
+}
@@ -0,0 +1,72 @@
+package elastic
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+type titleDoc struct {
+	Title string `json:"title"`
+}
+
+func (titleDoc) IsDoc() {}
+
+func TestPercolateDocs(t *testing.T) {
+	var sent map[string]interface{}
+	useTransport(t, &fakeTransport{handler: func(req *http.Request) (*http.Response, error) {
+		if req.Method == http.MethodHead {
+			return jsonResponse(http.StatusOK, `{}`), nil
+		}
+		b, _ := io.ReadAll(req.Body)
+		json.Unmarshal(b, &sent)
+		return jsonResponse(http.StatusOK, `{"hits":{"total":{"value":2},"hits":[
+			{"_id":"q1","fields":{"_percolator_document_slot":[0,2]}},
+			{"_id":"q2","fields":{"_percolator_document_slot":[2]}}
+		]}}`), nil
+	}})
+
+	matches, err := PercolateDocs("alerts", []Doc{titleDoc{"a"}, titleDoc{"b"}, titleDoc{"c"}}, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"q1"}, nil, {"q1", "q2"}}
+	if !reflect.DeepEqual(matches, want) {
+		t.Errorf("got %v, want %v", matches, want)
+	}
+
+	percolate, _ := sent["query"].(map[string]interface{})["percolate"].(map[string]interface{})
+	if documents, _ := percolate["documents"].([]interface{}); len(documents) != 3 || percolate["field"] != PercolatorField {
+		t.Errorf("sent %v", sent)
+	}
+}
+
+func TestPercolateDocSingleSlot(t *testing.T) {
+	useTransport(t, &fakeTransport{handler: func(req *http.Request) (*http.Response, error) {
+		if req.Method == http.MethodHead {
+			return jsonResponse(http.StatusOK, `{}`), nil
+		}
+		// elastic gives no slot for a single doc
+		return jsonResponse(http.StatusOK, `{"hits":{"total":{"value":1},"hits":[{"_id":"q1"}]}}`), nil
+	}})
+
+	ids, err := PercolateDoc("alerts", titleDoc{"a"}, 5)
+	if err != nil || !reflect.DeepEqual(ids, []string{"q1"}) {
+		t.Errorf("got %v, %v", ids, err)
+	}
+}
+
+func TestPercolateDocsTooManyMatches(t *testing.T) {
+	useTransport(t, &fakeTransport{handler: func(req *http.Request) (*http.Response, error) {
+		if req.Method == http.MethodHead {
+			return jsonResponse(http.StatusOK, `{}`), nil
+		}
+		return jsonResponse(http.StatusOK, `{"hits":{"total":{"value":20000},"hits":[{"_id":"q1"}]}}`), nil
+	}})
+
+	if _, err := PercolateDoc("alerts", titleDoc{"a"}, 5); err == nil {
+		t.Error("no error when matches are truncated")
+	}
+}
@@ -0,0 +1,207 @@
+package elastic
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// Pipeline is an ingest pipeline
+// processors are written as in elastic, e.g. {"html_strip": {"field": "content"}}
+type Pipeline struct {
+	Description string                   `json:"description,omitempty"`
+	Processors  []map[string]interface{} `json:"processors"`
+	OnFailure   []map[string]interface{} `json:"on_failure,omitempty"`
+}
+
+// SimulateResult is the result of a simulated doc
+// Source is the doc after the pipeline, Error the failure that stopped it (if any)
+type SimulateResult struct {
+	Source     map[string]interface{}
+	Error      string
+	Processors []ProcessorResult
+}
+
+// ProcessorResult is the result of one processor for a simulated doc
+// Status is success, error, error_ignored, skipped or dropped
+type ProcessorResult struct {
+	Type   string
+	Tag    string
+	Status string
+	Source map[string]interface{}
+	Error  string
+}
+
+// WriteOption configures write calls (SaveDoc, DataStreamSaveDoc, UpdateByQuery, Bulk)
+type WriteOption func(*writeOptions)
+
+type writeOptions struct {
+	pipeline string
+}
+
+// WithPipeline processes written docs with an ingest pipeline
+func WithPipeline(id string) WriteOption {
+	return func(o *writeOptions) {
+		o.pipeline = id
+	}
+}
+
+// applyWriteOptions returns the options resulting of opts
+func applyWriteOptions(opts []WriteOption) writeOptions {
+	var o writeOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// PutPipeline creates or replaces an ingest pipeline
+func PutPipeline(id string, pipeline Pipeline, timeOut int) error {
+
+	// CHECKS
+	if id == "" || len(pipeline.Processors) == 0 {
+		return fmt.Errorf("pipeline id and processors are required")
+	}
+
+	body, err := json.Marshal(pipeline)
+	if err != nil {
+		return err
+	}
+
+	// Set up the request object.
+	req := esapi.IngestPutPipelineRequest{
+		PipelineID: id,
+		Body:       bytes.NewReader(body),
+	}
+
+	_, err = doRequest(req, "PutPipeline", timeOut)
+	return err
+}
+
+// GetPipeline returns an ingest pipeline
+func GetPipeline(id string, timeOut int) (Pipeline, error) {
+
+	var pipeline Pipeline
+
+	// Set up the request object.
+	req := esapi.IngestGetPipelineRequest{
+		PipelineID: id,
+	}
+
+	r, err := doRequest(req, "GetPipeline", timeOut)
+	if err != nil {
+		return pipeline, err
+	}
+
+	p, ok := r[id]
+	if !ok {
+		return pipeline, fmt.Errorf("no pipeline with id '%s'", id)
+	}
+
+	// the response has the shape of the pipeline
+	b, err := json.Marshal(p)
+	if err != nil {
+		return pipeline, err
+	}
+	if err := json.Unmarshal(b, &pipeline); err != nil {
+		return pipeline, fmt.Errorf("error parsing pipeline: %s", err)
+	}
+
+	return pipeline, nil
+}
+
+// DeletePipeline deletes an ingest pipeline
+func DeletePipeline(id string, timeOut int) error {
+
+	// Set up the request object.
+	req := esapi.IngestDeletePipelineRequest{
+		PipelineID: id,
+	}
+
+	_, err := doRequest(req, "DeletePipeline", timeOut)
+	return err
+}
+
+// SimulatePipeline runs docs through a stored pipeline (by id) or through the given pipeline
+// nothing is indexed, results are in the order of docs
+func SimulatePipeline(id string, pipeline *Pipeline, docs []Doc, timeOut int) ([]SimulateResult, error) {
+
+	var results []SimulateResult
+
+	// CHECKS
+	if (id == "") == (pipeline == nil) {
+		return results, fmt.Errorf("either a pipeline id or a pipeline is required")
+	}
+
+	var sources []map[string]interface{}
+	for _, d := range docs {
+		sources = append(sources, map[string]interface{}{"_source": d})
+	}
+
+	b := map[string]interface{}{"docs": sources}
+	if pipeline != nil {
+		b["pipeline"] = pipeline
+	}
+
+	body, err := json.Marshal(b)
+	if err != nil {
+		return results, err
+	}
+
+	verbose := true
+	// Set up the request object.
+	req := esapi.IngestSimulateRequest{
+		PipelineID: id,
+		Body:       bytes.NewReader(body),
+		Verbose:    &verbose,
+	}
+
+	r, err := doRequest(req, "SimulatePipeline", timeOut)
+	if err != nil {
+		return results, err
+	}
+
+	list, _ := r["docs"].([]interface{})
+	for _, element := range list {
+		d, _ := element.(map[string]interface{})
+
+		var result SimulateResult
+		processors, _ := d["processor_results"].([]interface{})
+		for _, p := range processors {
+			processor, ok := p.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			var pr ProcessorResult
+			pr.Type, _ = processor["processor_type"].(string)
+			pr.Tag, _ = processor["tag"].(string)
+			pr.Status, _ = processor["status"].(string)
+			if doc, ok := processor["doc"].(map[string]interface{}); ok {
+				pr.Source, _ = doc["_source"].(map[string]interface{})
+				result.Source = pr.Source
+			}
+			pr.Error = errorReason(processor["error"])
+			if pr.Status == "error" {
+				result.Error = pr.Error
+			}
+
+			result.Processors = append(result.Processors, pr)
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// errorReason formats an error object of a response, empty when there is none
+func errorReason(v interface{}) string {
+	e, ok := v.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("%v: %v", e["type"], e["reason"])
+}
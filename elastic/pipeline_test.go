@@ -0,0 +1,125 @@
+package elastic
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestErrorReason(t *testing.T) {
+	e := map[string]interface{}{"type": "illegal_argument_exception", "reason": "field [x] not present"}
+	if got := errorReason(e); got != "illegal_argument_exception: field [x] not present" {
+		t.Errorf("got %q", got)
+	}
+	if got := errorReason(nil); got != "" {
+		t.Errorf("got %q", got)
+	}
+	if got := errorReason("error"); got != "" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestSimulatePipeline(t *testing.T) {
+	var sent map[string]interface{}
+	transport := &fakeTransport{handler: func(req *http.Request) (*http.Response, error) {
+		b, _ := io.ReadAll(req.Body)
+		json.Unmarshal(b, &sent)
+		return jsonResponse(http.StatusOK, `{"docs":[
+			{"processor_results":[
+				{"processor_type":"lowercase","tag":"lc","status":"success","doc":{"_source":{"title":"go"}}},
+				{"processor_type":"html_strip","status":"success","doc":{"_source":{"title":"go!"}}}
+			]},
+			{"processor_results":[
+				{"processor_type":"lowercase","status":"error","error":{"type":"illegal_argument_exception","reason":"field [title] not present"}}
+			]}
+		]}`), nil
+	}}
+	useTransport(t, transport)
+
+	pipeline := &Pipeline{Processors: []map[string]interface{}{{"lowercase": map[string]interface{}{"field": "title"}}}}
+	results, err := SimulatePipeline("", pipeline, []Doc{titleDoc{Title: "GO"}, titleDoc{}}, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := transport.count(http.MethodPost, "/_ingest/pipeline/_simulate"); n != 1 {
+		t.Errorf("%d simulate requests, want 1", n)
+	}
+	if docs, _ := sent["docs"].([]interface{}); len(docs) != 2 || sent["pipeline"] == nil {
+		t.Errorf("sent %v", sent)
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("got %d results", len(results))
+	}
+	// the source is the one after the last processor
+	if results[0].Source["title"] != "go!" || results[0].Error != "" || len(results[0].Processors) != 2 {
+		t.Errorf("first result %+v", results[0])
+	}
+	if results[0].Processors[0].Tag != "lc" || results[0].Processors[0].Type != "lowercase" {
+		t.Errorf("first processor %+v", results[0].Processors[0])
+	}
+	if results[1].Error != "illegal_argument_exception: field [title] not present" {
+		t.Errorf("second result %+v", results[1])
+	}
+
+	// either an id or a pipeline
+	if _, err := SimulatePipeline("", nil, nil, 5); err == nil {
+		t.Error("no error without a pipeline")
+	}
+	if _, err := SimulatePipeline("strip", pipeline, nil, 5); err == nil {
+		t.Error("no error with an id and a pipeline")
+	}
+}
+
+func TestGetPipeline(t *testing.T) {
+	useTransport(t, &fakeTransport{handler: func(req *http.Request) (*http.Response, error) {
+		return jsonResponse(http.StatusOK, `{"strip":{"description":"strip html","processors":[{"html_strip":{"field":"content"}}]}}`), nil
+	}})
+
+	pipeline, err := GetPipeline("strip", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pipeline.Description != "strip html" || len(pipeline.Processors) != 1 || pipeline.Processors[0]["html_strip"] == nil {
+		t.Errorf("got %+v", pipeline)
+	}
+
+	if _, err := GetPipeline("other", 5); err == nil {
+		t.Error("no error for a missing pipeline")
+	}
+}
+
+func TestWithPipeline(t *testing.T) {
+	transport := &fakeTransport{handler: func(req *http.Request) (*http.Response, error) {
+		if req.Method == http.MethodHead {
+			return jsonResponse(http.StatusOK, `{}`), nil
+		}
+		if req.URL.Path == "/_bulk" {
+			return jsonResponse(http.StatusOK, `{"errors":false,"items":[{"index":{"_id":"1","status":201}}]}`), nil
+		}
+		return jsonResponse(http.StatusCreated, `{"_id":"1","result":"created"}`), nil
+	}}
+	useTransport(t, transport)
+
+	if _, err := SaveDoc("posts", titleDoc{Title: "go"}, 5, WithPipeline("strip")); err != nil {
+		t.Fatal(err)
+	}
+	actions := []BulkAction{{Action: "index", Index: "posts", Id: "1", Doc: titleDoc{Title: "go"}}}
+	if _, err := Bulk(actions, false, 5, WithPipeline("strip")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SaveDoc("posts", titleDoc{Title: "go"}, 5); err != nil {
+		t.Fatal(err)
+	}
+
+	var pipelines []string
+	for _, req := range transport.requests {
+		if req.Method != http.MethodHead {
+			pipelines = append(pipelines, req.URL.Query().Get("pipeline"))
+		}
+	}
+	if len(pipelines) != 3 || pipelines[0] != "strip" || pipelines[1] != "strip" || pipelines[2] != "" {
+		t.Errorf("pipeline params %v", pipelines)
+	}
+}
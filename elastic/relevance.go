@@ -0,0 +1,278 @@
+package elastic
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// Token is a token produced by an analyzer
+type Token struct {
+	Token       string
+	Type        string
+	Position    int
+	StartOffset int
+	EndOffset   int
+}
+
+// Explanation is the score computation of a doc for a query, as a tree
+type Explanation struct {
+	Value       float64
+	Description string
+	Details     []Explanation
+}
+
+// QueryValidation is the result of a query validation
+type QueryValidation struct {
+	Valid        bool
+	Error        string
+	Explanations []QueryExplanation
+}
+
+// QueryExplanation is the rewritten query (or the error) for an index
+type QueryExplanation struct {
+	Index       string
+	Valid       bool
+	Explanation string
+	Error       string
+}
+
+// ShardProfile is the profile of a search on a shard
+type ShardProfile struct {
+	Id      string
+	Queries []QueryProfile
+}
+
+// QueryProfile is the time spent in a (sub)query
+type QueryProfile struct {
+	Type        string
+	Description string
+	TimeNanos   int64
+	Children    []QueryProfile
+}
+
+// Analyze runs text through an analyzer of an index, or through the analyzer of a field
+// give either analyzer or field, index is optional for built-in analyzers
+func Analyze(index, analyzer, field, text string, timeOut int) ([]Token, error) {
+
+	var tokens []Token
+
+	// CHECKS
+	if analyzer != "" && field != "" {
+		return tokens, fmt.Errorf("analyzer and field are exclusive")
+	}
+	if field != "" && index == "" {
+		return tokens, fmt.Errorf("an index is required to analyze with a field")
+	}
+
+	b := map[string]interface{}{"text": text}
+	if analyzer != "" {
+		b["analyzer"] = analyzer
+	}
+	if field != "" {
+		b["field"] = field
+	}
+
+	body, err := json.Marshal(b)
+	if err != nil {
+		return tokens, err
+	}
+
+	// Set up the request object.
+	req := esapi.IndicesAnalyzeRequest{
+		Index: index,
+		Body:  bytes.NewReader(body),
+	}
+
+	r, err := doRequest(req, "Analyze", timeOut)
+	if err != nil {
+		return tokens, err
+	}
+
+	list, _ := r["tokens"].([]interface{})
+	for _, element := range list {
+		t, ok := element.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		var token Token
+		token.Token, _ = t["token"].(string)
+		token.Type, _ = t["type"].(string)
+		token.Position = toInt(t["position"])
+		token.StartOffset = toInt(t["start_offset"])
+		token.EndOffset = toInt(t["end_offset"])
+		tokens = append(tokens, token)
+	}
+
+	return tokens, nil
+}
+
+// Explain returns whether a doc matches a query and how it is scored
+// the query is a search body, only its "query" part is used
+func Explain(index, id string, query map[string]interface{}, timeOut int) (bool, Explanation, error) {
+
+	var explanation Explanation
+
+	// CHECKS
+	q, ok := query["query"]
+	if !ok {
+		return false, explanation, fmt.Errorf("the search body has no query")
+	}
+
+	body, err := json.Marshal(map[string]interface{}{"query": q})
+	if err != nil {
+		return false, explanation, err
+	}
+
+	// Set up the request object.
+	req := esapi.ExplainRequest{
+		Index:      index,
+		DocumentID: id,
+		Body:       bytes.NewReader(body),
+	}
+
+	r, err := doRequest(req, "Explain", timeOut)
+	if err != nil {
+		return false, explanation, err
+	}
+
+	matched, _ := r["matched"].(bool)
+	if e, ok := r["explanation"].(map[string]interface{}); ok {
+		explanation = parseExplanation(e)
+	}
+
+	return matched, explanation, nil
+}
+
+// String returns the explanation as an indented tree
+func (e Explanation) String() string {
+	var sb strings.Builder
+	e.write(&sb, 0)
+	return sb.String()
+}
+
+func (e Explanation) write(sb *strings.Builder, depth int) {
+	fmt.Fprintf(sb, "%s%v %s\n", strings.Repeat("  ", depth), e.Value, e.Description)
+	for _, d := range e.Details {
+		d.write(sb, depth+1)
+	}
+}
+
+// parseExplanation converts an explanation of a response
+func parseExplanation(e map[string]interface{}) Explanation {
+	var explanation Explanation
+	explanation.Value, _ = e["value"].(float64)
+	explanation.Description, _ = e["description"].(string)
+
+	details, _ := e["details"].([]interface{})
+	for _, element := range details {
+		if d, ok := element.(map[string]interface{}); ok {
+			explanation.Details = append(explanation.Details, parseExplanation(d))
+		}
+	}
+
+	return explanation
+}
+
+// ValidateQuery checks a query without executing it and explains how it is rewritten
+// the query is a search body, only its "query" part is used
+func ValidateQuery(indices []string, query map[string]interface{}, timeOut int) (QueryValidation, error) {
+
+	var validation QueryValidation
+
+	// CHECKS
+	q, ok := query["query"]
+	if !ok {
+		return validation, fmt.Errorf("the search body has no query")
+	}
+
+	body, err := json.Marshal(map[string]interface{}{"query": q})
+	if err != nil {
+		return validation, err
+	}
+
+	explain := true
+	// Set up the request object.
+	req := esapi.IndicesValidateQueryRequest{
+		Index:   indices,
+		Body:    bytes.NewReader(body),
+		Explain: &explain,
+	}
+
+	r, err := doRequest(req, "ValidateQuery", timeOut)
+	if err != nil {
+		return validation, err
+	}
+
+	validation.Valid, _ = r["valid"].(bool)
+	validation.Error, _ = r["error"].(string)
+
+	list, _ := r["explanations"].([]interface{})
+	for _, element := range list {
+		e, ok := element.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		var qe QueryExplanation
+		qe.Index, _ = e["index"].(string)
+		qe.Valid, _ = e["valid"].(bool)
+		qe.Explanation, _ = e["explanation"].(string)
+		qe.Error, _ = e["error"].(string)
+		validation.Explanations = append(validation.Explanations, qe)
+	}
+
+	return validation, nil
+}
+
+// parseProfile converts the "profile" part of a search response
+func parseProfile(r map[string]interface{}) []ShardProfile {
+	var profiles []ShardProfile
+
+	profile, _ := r["profile"].(map[string]interface{})
+	shards, _ := profile["shards"].([]interface{})
+	for _, element := range shards {
+		shard, ok := element.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		var sp ShardProfile
+		sp.Id, _ = shard["id"].(string)
+		searches, _ := shard["searches"].([]interface{})
+		for _, s := range searches {
+			search, _ := s.(map[string]interface{})
+			queries, _ := search["query"].([]interface{})
+			sp.Queries = append(sp.Queries, parseQueryProfiles(queries)...)
+		}
+		profiles = append(profiles, sp)
+	}
+
+	return profiles
+}
+
+// parseQueryProfiles converts queries profiles, recursively
+func parseQueryProfiles(list []interface{}) []QueryProfile {
+	var profiles []QueryProfile
+
+	for _, element := range list {
+		q, ok := element.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		var qp QueryProfile
+		qp.Type, _ = q["type"].(string)
+		qp.Description, _ = q["description"].(string)
+		qp.TimeNanos = toInt64(q["time_in_nanos"])
+		children, _ := q["children"].([]interface{})
+		qp.Children = parseQueryProfiles(children)
+		profiles = append(profiles, qp)
+	}
+
+	return profiles
+}
@@ -0,0 +1,124 @@
+package elastic
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	transport := &fakeTransport{handler: func(req *http.Request) (*http.Response, error) {
+		return jsonResponse(http.StatusOK, `{"tokens":[
+			{"token":"quick","type":"<ALPHANUM>","position":0,"start_offset":0,"end_offset":5},
+			{"token":"fox","type":"<ALPHANUM>","position":1,"start_offset":6,"end_offset":9}
+		]}`), nil
+	}}
+	useTransport(t, transport)
+
+	tokens, err := Analyze("posts", "", "title", "Quick fox", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Token{
+		{Token: "quick", Type: "<ALPHANUM>", Position: 0, StartOffset: 0, EndOffset: 5},
+		{Token: "fox", Type: "<ALPHANUM>", Position: 1, StartOffset: 6, EndOffset: 9},
+	}
+	if len(tokens) != 2 || tokens[0] != want[0] || tokens[1] != want[1] {
+		t.Errorf("got %+v", tokens)
+	}
+	if n := transport.count(http.MethodPost, "/posts/_analyze"); n != 1 {
+		t.Errorf("%d analyze requests, want 1", n)
+	}
+
+	if _, err := Analyze("posts", "standard", "title", "x", 5); err == nil {
+		t.Error("no error with an analyzer and a field")
+	}
+	if _, err := Analyze("", "", "title", "x", 5); err == nil {
+		t.Error("no error for a field without index")
+	}
+}
+
+func TestParseExplanation(t *testing.T) {
+	var e map[string]interface{}
+	json.Unmarshal([]byte(`{"value":1.5,"description":"sum of:","details":[
+		{"value":1.0,"description":"weight(title:go)","details":[]},
+		{"value":0.5,"description":"weight(body:go)"}
+	]}`), &e)
+
+	explanation := parseExplanation(e)
+	if explanation.Value != 1.5 || explanation.Description != "sum of:" || len(explanation.Details) != 2 {
+		t.Fatalf("got %+v", explanation)
+	}
+	if explanation.Details[1].Value != 0.5 || explanation.Details[1].Description != "weight(body:go)" {
+		t.Errorf("detail %+v", explanation.Details[1])
+	}
+
+	want := "1.5 sum of:\n  1 weight(title:go)\n  0.5 weight(body:go)\n"
+	if got := explanation.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestExplain(t *testing.T) {
+	useTransport(t, &fakeTransport{handler: func(req *http.Request) (*http.Response, error) {
+		return jsonResponse(http.StatusOK, `{"matched":true,"explanation":{"value":2.0,"description":"score"}}`), nil
+	}})
+
+	matched, explanation, err := Explain("posts", "1", map[string]interface{}{"query": map[string]interface{}{"match_all": map[string]interface{}{}}}, 5)
+	if err != nil || !matched || explanation.Value != 2 {
+		t.Errorf("got %v, %+v, %v", matched, explanation, err)
+	}
+
+	if _, _, err := Explain("posts", "1", map[string]interface{}{"size": 1}, 5); err == nil {
+		t.Error("no error without query")
+	}
+}
+
+func TestValidateQuery(t *testing.T) {
+	useTransport(t, &fakeTransport{handler: func(req *http.Request) (*http.Response, error) {
+		return jsonResponse(http.StatusOK, `{"valid":false,"explanations":[
+			{"index":"posts","valid":true,"explanation":"title:go"},
+			{"index":"logs","valid":false,"error":"failed to parse date field"}
+		]}`), nil
+	}})
+
+	validation, err := ValidateQuery([]string{"posts", "logs"}, map[string]interface{}{"query": map[string]interface{}{"term": map[string]interface{}{"title": "go"}}}, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if validation.Valid || len(validation.Explanations) != 2 {
+		t.Fatalf("got %+v", validation)
+	}
+	if e := validation.Explanations[0]; e.Index != "posts" || !e.Valid || e.Explanation != "title:go" {
+		t.Errorf("first explanation %+v", e)
+	}
+	if e := validation.Explanations[1]; e.Valid || e.Error != "failed to parse date field" {
+		t.Errorf("second explanation %+v", e)
+	}
+}
+
+func TestParseProfile(t *testing.T) {
+	var r map[string]interface{}
+	json.Unmarshal([]byte(`{"profile":{"shards":[{"id":"[node][posts][0]","searches":[{"query":[
+		{"type":"BooleanQuery","description":"+title:go +body:go","time_in_nanos":1200,"children":[
+			{"type":"TermQuery","description":"title:go","time_in_nanos":700},
+			{"type":"TermQuery","description":"body:go","time_in_nanos":500}
+		]}
+	]}]}]}}`), &r)
+
+	profiles := parseProfile(r)
+	if len(profiles) != 1 || profiles[0].Id != "[node][posts][0]" || len(profiles[0].Queries) != 1 {
+		t.Fatalf("got %+v", profiles)
+	}
+	q := profiles[0].Queries[0]
+	if q.Type != "BooleanQuery" || q.TimeNanos != 1200 || len(q.Children) != 2 {
+		t.Errorf("query %+v", q)
+	}
+	if q.Children[1].Description != "body:go" || q.Children[1].TimeNanos != 500 {
+		t.Errorf("child %+v", q.Children[1])
+	}
+
+	if profiles := parseProfile(map[string]interface{}{}); profiles != nil {
+		t.Errorf("got %+v without profile", profiles)
+	}
+}
@@ -1,8 +1,10 @@
 package elastic
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/elastic/go-elasticsearch/v8/esapi"
 )
@@ -44,4 +46,100 @@ func getResponseMap(res *esapi.Response) (map[string]interface{}, error) {
 	}
 
 	return r, nil
-}
\ No newline at end of file
+}
+
+// doRequest performs an esapi request with a timeout in seconds
+// name prefixes the timeout error
+func doRequest(req esapi.Request, name string, timeOut int) (map[string]interface{}, error) {
+
+	// Set up a context with a timeout.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOut)*time.Second)
+	defer cancel()
+
+	// Perform the request with the client.
+	res, err := req.Do(ctx, esTransport)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("%s - request timed out", name)
+		}
+		return nil, fmt.Errorf("es error getting response: %w", err)
+	}
+
+	// Securely close Body
+	if res.Body != nil {
+		defer res.Body.Close()
+	}
+
+	//  deserialize response and possible errors
+	return getResponseMap(res)
+}
+
+// doRequestList performs an esapi request whose response is a JSON array (cat apis)
+// name prefixes the timeout error
+func doRequestList(req esapi.Request, name string, timeOut int) ([]interface{}, error) {
+
+	// Set up a context with a timeout.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOut)*time.Second)
+	defer cancel()
+
+	// Perform the request with the client.
+	res, err := req.Do(ctx, esTransport)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("%s - request timed out", name)
+		}
+		return nil, fmt.Errorf("es error getting response: %w", err)
+	}
+
+	// Securely close Body
+	if res.Body != nil {
+		defer res.Body.Close()
+	}
+
+	// errors are objects
+	if res.IsError() {
+		_, err := getResponseMap(res)
+		return nil, err
+	}
+
+	var l []interface{}
+	if err := json.NewDecoder(res.Body).Decode(&l); err != nil {
+		return nil, fmt.Errorf("doRequest(): decoding the response body: %s", err)
+	}
+
+	return l, nil
+}
+
+// doRequestDecode performs a request and decodes the response into v
+// unlike doRequest, v may keep values as they are (json.RawMessage, json.Number)
+func doRequestDecode(req esapi.Request, name string, timeOut int, v interface{}) error {
+
+	// Set up a context with a timeout.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOut)*time.Second)
+	defer cancel()
+
+	// Perform the request with the client.
+	res, err := req.Do(ctx, esTransport)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("%s - request timed out", name)
+		}
+		return fmt.Errorf("es error getting response: %w", err)
+	}
+
+	// Securely close Body
+	if res.Body != nil {
+		defer res.Body.Close()
+	}
+
+	if res.IsError() {
+		_, err := getResponseMap(res)
+		return err
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		return fmt.Errorf("doRequest(): decoding the response body: %s", err)
+	}
+
+	return nil
+}
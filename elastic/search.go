@@ -10,23 +10,45 @@ import (
 	"github.com/elastic/go-elasticsearch/v8/esapi"
 )
 
+// SearchOption configures Search
+type SearchOption func(*searchOptions)
+
+type searchOptions struct {
+	ignoreUnavailable bool
+	allowNoIndices    *bool
+}
+
+// WithIgnoreUnavailable ignores missing or closed indices (no existence check is done)
+func WithIgnoreUnavailable() SearchOption {
+	return func(o *searchOptions) {
+		o.ignoreUnavailable = true
+	}
+}
+
+// WithAllowNoIndices tells if a pattern matching no index is allowed (elastic default is true)
+func WithAllowNoIndices(allow bool) SearchOption {
+	return func(o *searchOptions) {
+		o.allowNoIndices = &allow
+	}
+}
+
 // search api
+// indices can be patterns (logs-*), aliases, data streams or cross-cluster targets (remote:index)
+// when some shards or clusters failed, the hits found are returned with a *PartialResultsError
 // return hits, total, err
-func Search(indices []string, query map[string]interface{}, timeOut int) ([]map[string]interface{}, int, error) {
+func Search(indices []string, query map[string]interface{}, timeOut int, opts ...SearchOption) ([]map[string]interface{}, int, error) {
 
 	var hits []map[string]interface{}
 	var total int
 
-	// CHECKS
-	for _, index := range indices {
-		exists, err := IndexExists(index)
-		if err != nil {
-			return hits, total, fmt.Errorf("index exist err: %s", err)
-		}
+	var o searchOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
 
-		if !exists {
-			return hits, total, fmt.Errorf("index %v doesn't exist or index not included in elastic role for this user", index)
-		}
+	// CHECKS
+	if err := checkSearchIndices(indices, o.ignoreUnavailable); err != nil {
+		return hits, total, err
 	}
 
 	body, err := json.Marshal(query)
@@ -43,12 +65,16 @@ func Search(indices []string, query map[string]interface{}, timeOut int) ([]map[
 		Index:          indices,
 		Body:           bytes.NewReader(body),
 		TrackTotalHits: true,
+		AllowNoIndices: o.allowNoIndices,
+	}
+	if o.ignoreUnavailable {
+		req.IgnoreUnavailable = &o.ignoreUnavailable
 	}
 
 	// Perform the request with the client.
-	res, err := req.Do(ctx, es)
+	res, err := req.Do(ctx, esTransport)
 	if err != nil {
-		return hits, total, fmt.Errorf("es error getting response: %s", err)
+		return hits, total, fmt.Errorf("es error getting response: %w", err)
 	}
 
 	// Securely close Body
@@ -62,20 +88,298 @@ func Search(indices []string, query map[string]interface{}, timeOut int) ([]map[
 		return hits, total, err
 	}
 
-	for _, hit := range r["hits"].(map[string]interface{})["hits"].([]interface{}) {
-		source := hit.(map[string]interface{})["_source"]
-		id := hit.(map[string]interface{})["_id"]
-		highlight := hit.(map[string]interface{})["highlight"]
-		index := hit.(map[string]interface{})["_index"]
+	found, total := parseHits(r)
+	for _, hit := range found {
 		m := make(map[string]interface{})
-		m["id"] = id
-		m["index"] = index
-		m["source"] = source
-		m["highlight"] = highlight
+		m["id"] = hit.Id
+		m["index"] = hit.Index
+		m["source"] = nil
+		if hit.Source != nil {
+			m["source"] = hit.Source
+		}
+		m["highlight"] = nil
+		if hit.Highlight != nil {
+			highlight := make(map[string]interface{}, len(hit.Highlight))
+			for field, fragments := range hit.Highlight {
+				list := make([]interface{}, len(fragments))
+				for i, f := range fragments {
+					list[i] = f
+				}
+				highlight[field] = list
+			}
+			m["highlight"] = highlight
+		}
 		hits = append(hits, m)
 	}
-	// Print the response status, number of results, and request duration.
-	total = int(r["hits"].(map[string]interface{})["total"].(map[string]interface{})["value"].(float64))
+
+	shards := parseShards(r["_shards"])
+	clusters := parseClusters(r["_clusters"])
+	timedOut, _ := r["timed_out"].(bool)
+	if isPartial(shards, clusters, timedOut) {
+		return hits, total, &PartialResultsError{Shards: shards, Clusters: clusters}
+	}
 
 	return hits, total, nil
 }
+
+// Count returns the count of docs matching the query
+// the query is a search body, only its "query" part is used (all docs when nil)
+func Count(indices []string, query map[string]interface{}, timeOut int) (int, error) {
+
+	var count int
+
+	// CHECKS
+	if err := checkSearchIndices(indices, false); err != nil {
+		return count, err
+	}
+
+	// Set up the request object.
+	req := esapi.CountRequest{
+		Index: indices,
+	}
+
+	if q, ok := query["query"]; ok {
+		body, err := json.Marshal(map[string]interface{}{"query": q})
+		if err != nil {
+			return count, err
+		}
+		req.Body = bytes.NewReader(body)
+	}
+
+	r, err := doRequest(req, "Count", timeOut)
+	if err != nil {
+		return count, err
+	}
+
+	count = toInt(r["count"])
+
+	return count, nil
+}
+
+// Hit is a typed search hit
+type Hit struct {
+	Id        string
+	Index     string
+	Score     float64
+	Source    map[string]interface{}
+	Highlight map[string][]string // fragments per field
+	Fields    map[string][]interface{}
+	Sort      []interface{} // sort values, used with search_after
+	// set when the search is collapsed
+	CollapseKey interface{}
+	InnerHits   map[string][]Hit // keyed by inner hits name
+}
+
+// SearchOptions are typed extensions merged into the query body of SearchWithOptions
+type SearchOptions struct {
+	Highlight *Highlight
+	Suggest   map[string]Suggester // keyed by suggestion name
+	Collapse  *Collapse
+	Profile   bool // time spent per query and shard, adds overhead
+	// missing or closed indices are ignored (no existence check is done)
+	IgnoreUnavailable bool
+	// a pattern matching no index is an error when false (elastic default is true)
+	AllowNoIndices *bool
+	// partial results are returned with a *PartialResultsError, as Search does
+	FailOnPartial bool
+}
+
+// SearchResult is a typed search response
+type SearchResult struct {
+	Hits         []Hit
+	Total        int
+	Suggestions  map[string][]Suggestion // keyed by suggestion name
+	TotalGroups  int                     // approximate, set with Collapse.CountGroups
+	Profile      []ShardProfile          // set with SearchOptions.Profile
+	TimedOut     bool
+	Shards       ShardsInfo
+	Clusters     *ClustersInfo          // set for cross-cluster searches
+	Aggregations map[string]interface{} // raw aggregations, see Buckets
+}
+
+// Buckets returns the buckets of a bucket aggregation with their doc counts, as Aggregation does
+func (r SearchResult) Buckets(aggregationName string) (map[string][]map[string]interface{}, error) {
+	return parseBuckets(r.Aggregations, aggregationName)
+}
+
+// SearchWithOptions is Search with typed options and a typed result
+// partial results are not an error unless FailOnPartial is set, check SearchResult.Partial
+// the query map is not modified
+func SearchWithOptions(indices []string, query map[string]interface{}, opts SearchOptions, timeOut int) (SearchResult, error) {
+
+	var result SearchResult
+
+	// CHECKS
+	if err := checkSearchIndices(indices, opts.IgnoreUnavailable); err != nil {
+		return result, err
+	}
+
+	q := make(map[string]interface{}, len(query)+2)
+	for k, v := range query {
+		q[k] = v
+	}
+	if opts.Highlight != nil {
+		q["highlight"] = opts.Highlight
+	}
+	if len(opts.Suggest) > 0 {
+		suggest := make(map[string]interface{}, len(opts.Suggest))
+		for name, suggester := range opts.Suggest {
+			s, err := suggester.body()
+			if err != nil {
+				return result, fmt.Errorf("suggester %s: %s", name, err)
+			}
+			suggest[name] = s
+		}
+		q["suggest"] = suggest
+	}
+	if opts.Profile {
+		q["profile"] = true
+	}
+	if opts.Collapse != nil {
+		if opts.Collapse.Field == "" {
+			return result, fmt.Errorf("collapse field is required")
+		}
+		q["collapse"] = opts.Collapse
+		if opts.Collapse.CountGroups {
+			if err := addTotalGroupsAggregation(q, opts.Collapse.Field); err != nil {
+				return result, err
+			}
+		}
+	}
+
+	body, err := json.Marshal(q)
+	if err != nil {
+		return result, err
+	}
+
+	// Set up a context with a timeout.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOut)*time.Second)
+	defer cancel()
+
+	// Set up the request object.
+	req := esapi.SearchRequest{
+		Index:          indices,
+		Body:           bytes.NewReader(body),
+		TrackTotalHits: true,
+		AllowNoIndices: opts.AllowNoIndices,
+	}
+	if opts.IgnoreUnavailable {
+		req.IgnoreUnavailable = &opts.IgnoreUnavailable
+	}
+
+	// Perform the request with the client.
+	res, err := req.Do(ctx, esTransport)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return result, fmt.Errorf("SearchWithOptions - request timed out")
+		}
+		return result, fmt.Errorf("es error getting response: %w", err)
+	}
+
+	// Securely close Body
+	if res.Body != nil {
+		defer res.Body.Close()
+	}
+
+	//  deserialize response and possible errors
+	r, err := getResponseMap(res)
+	if err != nil {
+		return result, err
+	}
+
+	result = parseSearchResult(r)
+	if opts.Collapse != nil {
+		setCollapseResult(&result, r, opts.Collapse)
+	}
+	if opts.FailOnPartial && result.Partial() {
+		return result, &PartialResultsError{Shards: result.Shards, Clusters: result.Clusters}
+	}
+
+	return result, nil
+}
+
+// parseSearchResult converts a search response into a typed result
+func parseSearchResult(r map[string]interface{}) SearchResult {
+	var result SearchResult
+	result.Hits, result.Total = parseHits(r)
+	result.Suggestions = parseSuggestions(r)
+	result.Profile = parseProfile(r)
+	result.TimedOut, _ = r["timed_out"].(bool)
+	result.Shards = parseShards(r["_shards"])
+	result.Clusters = parseClusters(r["_clusters"])
+	result.Aggregations, _ = r["aggregations"].(map[string]interface{})
+	return result
+}
+
+// parseHits converts the "hits" part of a search response into typed hits
+// return hits, total
+func parseHits(r map[string]interface{}) ([]Hit, int) {
+	var hits []Hit
+	var total int
+
+	h, ok := r["hits"].(map[string]interface{})
+	if !ok {
+		return hits, total
+	}
+
+	// an object, or a number with rest_total_hits_as_int
+	switch t := h["total"].(type) {
+	case map[string]interface{}:
+		if v, ok := t["value"].(float64); ok {
+			total = int(v)
+		}
+	case float64:
+		total = int(t)
+	}
+
+	list, _ := h["hits"].([]interface{})
+	for _, element := range list {
+		hit, ok := element.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		hits = append(hits, parseHit(hit))
+	}
+
+	return hits, total
+}
+
+// parseHit converts a single hit of a search response
+func parseHit(hit map[string]interface{}) Hit {
+	var h Hit
+	h.Id, _ = hit["_id"].(string)
+	h.Index, _ = hit["_index"].(string)
+	h.Score, _ = hit["_score"].(float64)
+	h.Source, _ = hit["_source"].(map[string]interface{})
+	h.Sort, _ = hit["sort"].([]interface{})
+
+	if highlight, ok := hit["highlight"].(map[string]interface{}); ok {
+		h.Highlight = make(map[string][]string, len(highlight))
+		for field, fragments := range highlight {
+			list, _ := fragments.([]interface{})
+			for _, fragment := range list {
+				if f, ok := fragment.(string); ok {
+					h.Highlight[field] = append(h.Highlight[field], f)
+				}
+			}
+		}
+	}
+
+	if fields, ok := hit["fields"].(map[string]interface{}); ok {
+		h.Fields = make(map[string][]interface{}, len(fields))
+		for field, values := range fields {
+			h.Fields[field], _ = values.([]interface{})
+		}
+	}
+
+	if innerHits, ok := hit["inner_hits"].(map[string]interface{}); ok {
+		h.InnerHits = make(map[string][]Hit, len(innerHits))
+		for name, inner := range innerHits {
+			if m, ok := inner.(map[string]interface{}); ok {
+				h.InnerHits[name], _ = parseHits(m)
+			}
+		}
+	}
+
+	return h
+}
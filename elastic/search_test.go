@@ -0,0 +1,76 @@
+package elastic
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+const partialSearchResponse = `{
+	"timed_out": false,
+	"_shards": {"total": 2, "successful": 1, "skipped": 0, "failed": 1,
+		"failures": [{"index": "logs-a", "shard": 1, "node": "n1", "reason": {"type": "exception", "reason": "shard failed"}}]},
+	"hits": {"total": {"value": 1, "relation": "eq"}, "hits": [{"_index": "logs-a", "_id": "1", "_source": {"a": 1}}]}
+}`
+
+func TestSearchPartialResults(t *testing.T) {
+	useTransport(t, &fakeTransport{handler: func(req *http.Request) (*http.Response, error) {
+		return jsonResponse(http.StatusOK, partialSearchResponse), nil
+	}})
+
+	// the hits found are returned with the error
+	hits, total, err := Search([]string{"logs-*"}, map[string]interface{}{}, 5)
+	var partial *PartialResultsError
+	if !errors.As(err, &partial) || partial.Shards.Failed != 1 || len(partial.Shards.Failures) != 1 {
+		t.Fatalf("Search: got %v", err)
+	}
+	if len(hits) != 1 || total != 1 {
+		t.Errorf("Search: got %d hits, total %d", len(hits), total)
+	}
+
+	result, err := SearchWithOptions([]string{"logs-*"}, map[string]interface{}{}, SearchOptions{}, 5)
+	if err != nil {
+		t.Fatalf("SearchWithOptions: %v", err)
+	}
+	if !result.Partial() || result.Shards.Failed != 1 || len(result.Hits) != 1 {
+		t.Errorf("SearchWithOptions: got %+v", result)
+	}
+
+	result, err = SearchWithOptions([]string{"logs-*"}, map[string]interface{}{}, SearchOptions{FailOnPartial: true}, 5)
+	if !errors.As(err, &partial) || partial.Shards.Failed != 1 {
+		t.Fatalf("FailOnPartial: got %v", err)
+	}
+	if len(result.Hits) != 1 {
+		t.Errorf("FailOnPartial: got %d hits", len(result.Hits))
+	}
+}
+
+func TestSearchOptions(t *testing.T) {
+	transport := &fakeTransport{handler: func(req *http.Request) (*http.Response, error) {
+		// no total with track_total_hits:false
+		return jsonResponse(http.StatusOK, `{"_shards":{"total":1,"successful":1,"failed":0},
+			"hits":{"hits":[{"_index":"posts","_id":"1","_source":{"a":1},"highlight":{"a":["<em>1</em>"]}}]}}`), nil
+	}}
+	useTransport(t, transport)
+
+	hits, total, err := Search([]string{"posts"}, map[string]interface{}{"track_total_hits": false}, 5, WithIgnoreUnavailable(), WithAllowNoIndices(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 1 || total != 0 || hits[0]["id"] != "1" {
+		t.Fatalf("got %v, total %d", hits, total)
+	}
+	highlight, _ := hits[0]["highlight"].(map[string]interface{})
+	if fragments, _ := highlight["a"].([]interface{}); len(fragments) != 1 {
+		t.Errorf("highlight: got %v", hits[0]["highlight"])
+	}
+
+	// no existence check, options sent to elastic
+	if n := transport.count(http.MethodHead, "/posts"); n != 0 {
+		t.Errorf("%d existence checks, want 0", n)
+	}
+	query := transport.requests[0].URL.Query()
+	if query.Get("ignore_unavailable") != "true" || query.Get("allow_no_indices") != "false" {
+		t.Errorf("got params %v", query)
+	}
+}
@@ -0,0 +1,181 @@
+package elastic
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ShardsInfo is the "_shards" part of a search response
+type ShardsInfo struct {
+	Total      int
+	Successful int
+	Skipped    int
+	Failed     int
+	Failures   []ShardFailure
+}
+
+// ShardFailure is the failure of a shard
+type ShardFailure struct {
+	Index  string
+	Shard  int
+	Node   string
+	Reason string
+}
+
+// ClustersInfo is the "_clusters" part of a cross-cluster search response
+type ClustersInfo struct {
+	Total      int
+	Successful int
+	Skipped    int
+	Running    int
+	Partial    int
+	Failed     int
+	Details    map[string]ClusterDetail // keyed by cluster alias, "(local)" for the local cluster
+}
+
+// ClusterDetail is the search status of a cluster
+// Status is successful, partial, skipped, running or failed
+type ClusterDetail struct {
+	Status   string
+	Indices  string
+	TimedOut bool
+	Shards   ShardsInfo
+	Failures []ShardFailure
+}
+
+// PartialResultsError is returned by Search, and SearchWithOptions with FailOnPartial, along with the hits when some shards or clusters failed
+type PartialResultsError struct {
+	Shards   ShardsInfo
+	Clusters *ClustersInfo
+}
+
+func (e *PartialResultsError) Error() string {
+	msg := fmt.Sprintf("partial results: %d/%d shards failed", e.Shards.Failed, e.Shards.Total)
+	if e.Clusters != nil && (e.Clusters.Skipped > 0 || e.Clusters.Partial > 0 || e.Clusters.Failed > 0) {
+		msg += fmt.Sprintf(", clusters: %d skipped, %d partial, %d failed", e.Clusters.Skipped, e.Clusters.Partial, e.Clusters.Failed)
+	}
+	if len(e.Shards.Failures) > 0 {
+		msg += ", first failure: " + e.Shards.Failures[0].Reason
+	}
+	return msg
+}
+
+// Partial tells if the result misses hits of failed shards or clusters
+func (r SearchResult) Partial() bool {
+	return isPartial(r.Shards, r.Clusters, r.TimedOut)
+}
+
+func isPartial(shards ShardsInfo, clusters *ClustersInfo, timedOut bool) bool {
+	if timedOut || shards.Failed > 0 {
+		return true
+	}
+	return clusters != nil && (clusters.Skipped > 0 || clusters.Partial > 0 || clusters.Failed > 0)
+}
+
+// isIndexPattern tells if a search target is resolved by elastic rather than a concrete local index:
+// wildcard, comma separated list, exclusion, cross-cluster target (remote:index) or _all
+func isIndexPattern(index string) bool {
+	return strings.ContainsAny(index, "*?,:") || strings.HasPrefix(index, "-") || index == "_all"
+}
+
+// checkSearchIndices checks that concrete local indices exist (aliases and data streams included)
+// patterns and cross-cluster targets are left to elastic, nothing is checked with ignoreUnavailable
+func checkSearchIndices(indices []string, ignoreUnavailable bool) error {
+	if ignoreUnavailable {
+		return nil
+	}
+
+	for _, index := range indices {
+		if isIndexPattern(index) {
+			continue
+		}
+
+		exists, err := IndexExists(index)
+		if err != nil {
+			return fmt.Errorf("index exist err: %w", err)
+		}
+
+		if !exists {
+			return fmt.Errorf("index %v doesn't exist or index not included in elastic role for this user", index)
+		}
+	}
+
+	return nil
+}
+
+// parseShards converts a "_shards" part of a response
+func parseShards(v interface{}) ShardsInfo {
+	var info ShardsInfo
+
+	s, ok := v.(map[string]interface{})
+	if !ok {
+		return info
+	}
+
+	info.Total = toInt(s["total"])
+	info.Successful = toInt(s["successful"])
+	info.Skipped = toInt(s["skipped"])
+	info.Failed = toInt(s["failed"])
+	info.Failures = parseShardFailures(s["failures"])
+
+	return info
+}
+
+// parseShardFailures converts a list of shard failures
+func parseShardFailures(v interface{}) []ShardFailure {
+	var failures []ShardFailure
+
+	list, _ := v.([]interface{})
+	for _, element := range list {
+		f, ok := element.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		var failure ShardFailure
+		failure.Index, _ = f["index"].(string)
+		failure.Node, _ = f["node"].(string)
+		failure.Shard = toInt(f["shard"])
+		failure.Reason = errorReason(f["reason"])
+		failures = append(failures, failure)
+	}
+
+	return failures
+}
+
+// parseClusters converts the "_clusters" part of a response, nil for local searches
+func parseClusters(v interface{}) *ClustersInfo {
+	c, ok := v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	info := &ClustersInfo{
+		Total:      toInt(c["total"]),
+		Successful: toInt(c["successful"]),
+		Skipped:    toInt(c["skipped"]),
+		Running:    toInt(c["running"]),
+		Partial:    toInt(c["partial"]),
+		Failed:     toInt(c["failed"]),
+	}
+
+	if details, ok := c["details"].(map[string]interface{}); ok {
+		info.Details = make(map[string]ClusterDetail, len(details))
+		for name, element := range details {
+			d, ok := element.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			var detail ClusterDetail
+			detail.Status, _ = d["status"].(string)
+			detail.Indices, _ = d["indices"].(string)
+			detail.TimedOut, _ = d["timed_out"].(bool)
+			detail.Shards = parseShards(d["_shards"])
+			detail.Failures = parseShardFailures(d["failures"])
+			info.Details[name] = detail
+		}
+	}
+
+	return info
+}
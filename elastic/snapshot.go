@@ -0,0 +1,322 @@
+package elastic
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// snapshots states
+const (
+	SnapshotInProgress = "IN_PROGRESS"
+	SnapshotSuccess    = "SUCCESS"
+	SnapshotFailed     = "FAILED"
+	SnapshotPartial    = "PARTIAL"
+)
+
+// SnapshotInfo describes a snapshot of a repository
+type SnapshotInfo struct {
+	Snapshot         string
+	State            string
+	Indices          []string
+	DataStreams      []string
+	StartTime        time.Time
+	EndTime          time.Time
+	ShardsTotal      int
+	ShardsSuccessful int
+	ShardsFailed     int
+}
+
+// SnapshotRetention selects snapshots to delete
+// a successful snapshot is deleted when it is older than MaxAge or beyond the MaxCount most recent ones,
+// the MinCount most recent successful snapshots are always kept, zero values disable a rule
+// failed snapshots are always deleted, partial ones only with DeletePartial
+// (a partial snapshot may be the only backup of some indices), other states are left alone
+type SnapshotRetention struct {
+	MaxAge        time.Duration
+	MaxCount      int
+	MinCount      int
+	DeletePartial bool
+}
+
+// RestoreOptions configures a restore
+// indices (and data streams) matching RenamePattern are restored as RenameReplacement, e.g. "(.+)" -> "restored_$1"
+// existing open indices cannot be overwritten, close or delete them first or rename them
+type RestoreOptions struct {
+	Indices            []string
+	RenamePattern      string
+	RenameReplacement  string
+	IncludeAliases     bool
+	IncludeGlobalState bool
+	WaitForCompletion  bool
+}
+
+// CreateFsRepository registers (creates or updates) a shared filesystem repository
+// location must be listed in the path.repo setting of every node,
+// e.g. a local single node started with -E path.repo=/tmp/es-backups
+func CreateFsRepository(name, location string, compress bool, timeOut int) error {
+
+	body, err := json.Marshal(map[string]interface{}{
+		"type": "fs",
+		"settings": map[string]interface{}{
+			"location": location,
+			"compress": compress,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Set up the request object.
+	req := esapi.SnapshotCreateRepositoryRequest{
+		Repository: name,
+		Body:       bytes.NewReader(body),
+	}
+
+	_, err = doRequest(req, "CreateFsRepository", timeOut)
+	return err
+}
+
+// DeleteRepository unregisters a repository, the snapshots files are kept
+func DeleteRepository(name string, timeOut int) error {
+
+	// Set up the request object.
+	req := esapi.SnapshotDeleteRepositoryRequest{
+		Repository: []string{name},
+	}
+
+	_, err := doRequest(req, "DeleteRepository", timeOut)
+	return err
+}
+
+// CreateSnapshot snapshots the given indices and data streams (all when empty)
+// when waitForCompletion is false the returned info only holds the snapshot name
+// otherwise a snapshot ending in another state than SUCCESS is an error, returned with its info
+func CreateSnapshot(repository, snapshot string, indices []string, waitForCompletion bool, timeOut int) (SnapshotInfo, error) {
+
+	info := SnapshotInfo{Snapshot: snapshot}
+
+	b := map[string]interface{}{
+		"include_global_state": false,
+	}
+	if len(indices) > 0 {
+		b["indices"] = strings.Join(indices, ",")
+	}
+
+	body, err := json.Marshal(b)
+	if err != nil {
+		return info, err
+	}
+
+	// Set up the request object.
+	req := esapi.SnapshotCreateRequest{
+		Repository:        repository,
+		Snapshot:          snapshot,
+		Body:              bytes.NewReader(body),
+		WaitForCompletion: &waitForCompletion,
+	}
+
+	r, err := doRequest(req, "CreateSnapshot", timeOut)
+	if err != nil {
+		return info, err
+	}
+
+	if s, ok := r["snapshot"].(map[string]interface{}); ok {
+		info = parseSnapshotInfo(s)
+	}
+
+	if waitForCompletion && info.State != SnapshotSuccess {
+		return info, fmt.Errorf("snapshot %s ended in state '%s', %d/%d shards failed", snapshot, info.State, info.ShardsFailed, info.ShardsTotal)
+	}
+
+	return info, nil
+}
+
+// ListSnapshots returns the snapshots of a repository, oldest first
+func ListSnapshots(repository string, timeOut int) ([]SnapshotInfo, error) {
+
+	var snapshots []SnapshotInfo
+
+	// Set up the request object.
+	req := esapi.SnapshotGetRequest{
+		Repository: repository,
+		Snapshot:   []string{"_all"},
+		Sort:       "start_time",
+		Order:      "asc",
+	}
+
+	r, err := doRequest(req, "ListSnapshots", timeOut)
+	if err != nil {
+		return snapshots, err
+	}
+
+	list, _ := r["snapshots"].([]interface{})
+	for _, element := range list {
+		if s, ok := element.(map[string]interface{}); ok {
+			snapshots = append(snapshots, parseSnapshotInfo(s))
+		}
+	}
+
+	return snapshots, nil
+}
+
+// DeleteSnapshots deletes snapshots of a repository
+func DeleteSnapshots(repository string, snapshots []string, timeOut int) error {
+
+	// CHECKS
+	if len(snapshots) == 0 {
+		return nil
+	}
+
+	// Set up the request object.
+	req := esapi.SnapshotDeleteRequest{
+		Repository: repository,
+		Snapshot:   snapshots,
+	}
+
+	_, err := doRequest(req, "DeleteSnapshots", timeOut)
+	return err
+}
+
+// ApplySnapshotRetention deletes the snapshots selected by the retention
+// in progress snapshots are never deleted
+// return names of deleted snapshots
+func ApplySnapshotRetention(repository string, retention SnapshotRetention, timeOut int) ([]string, error) {
+
+	snapshots, err := ListSnapshots(repository, timeOut)
+	if err != nil {
+		return nil, err
+	}
+
+	deleted := selectExpiredSnapshots(snapshots, retention, time.Now())
+	if err := DeleteSnapshots(repository, deleted, timeOut); err != nil {
+		return nil, err
+	}
+
+	return deleted, nil
+}
+
+// selectExpiredSnapshots returns names of snapshots to delete according to the retention
+func selectExpiredSnapshots(snapshots []SnapshotInfo, retention SnapshotRetention, now time.Time) []string {
+	var expired []string
+	var successful []SnapshotInfo
+
+	for _, s := range snapshots {
+		switch s.State {
+		case SnapshotSuccess:
+			successful = append(successful, s)
+		case SnapshotFailed:
+			expired = append(expired, s.Snapshot)
+		case SnapshotPartial:
+			if retention.DeletePartial {
+				expired = append(expired, s.Snapshot)
+			}
+		}
+	}
+
+	// most recent first
+	sort.Slice(successful, func(i, j int) bool {
+		return successful[i].StartTime.After(successful[j].StartTime)
+	})
+
+	for i, s := range successful {
+		if i < retention.MinCount {
+			continue
+		}
+		tooMany := retention.MaxCount > 0 && i >= retention.MaxCount
+		tooOld := retention.MaxAge > 0 && now.Sub(s.StartTime) > retention.MaxAge
+		if tooMany || tooOld {
+			expired = append(expired, s.Snapshot)
+		}
+	}
+
+	return expired
+}
+
+// RestoreSnapshot restores indices and data streams of a snapshot
+func RestoreSnapshot(repository, snapshot string, opts RestoreOptions, timeOut int) error {
+
+	b := map[string]interface{}{
+		"include_aliases":      opts.IncludeAliases,
+		"include_global_state": opts.IncludeGlobalState,
+	}
+	if len(opts.Indices) > 0 {
+		b["indices"] = strings.Join(opts.Indices, ",")
+	}
+	if opts.RenamePattern != "" {
+		b["rename_pattern"] = opts.RenamePattern
+		b["rename_replacement"] = opts.RenameReplacement
+	}
+
+	body, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+
+	// Set up the request object.
+	req := esapi.SnapshotRestoreRequest{
+		Repository:        repository,
+		Snapshot:          snapshot,
+		Body:              bytes.NewReader(body),
+		WaitForCompletion: &opts.WaitForCompletion,
+	}
+
+	r, err := doRequest(req, "RestoreSnapshot", timeOut)
+	if err != nil {
+		return err
+	}
+
+	// with wait_for_completion, shards failures are reported in the response
+	if s, ok := r["snapshot"].(map[string]interface{}); ok {
+		shards, _ := s["shards"].(map[string]interface{})
+		if failed, _ := shards["failed"].(float64); failed > 0 {
+			return fmt.Errorf("restore of %s: %v shards failed", snapshot, failed)
+		}
+	}
+
+	return nil
+}
+
+// parseSnapshotInfo converts a snapshot of a response
+func parseSnapshotInfo(s map[string]interface{}) SnapshotInfo {
+	var info SnapshotInfo
+	info.Snapshot, _ = s["snapshot"].(string)
+	info.State, _ = s["state"].(string)
+	info.Indices = toStrings(s["indices"])
+	info.DataStreams = toStrings(s["data_streams"])
+
+	if ms, ok := s["start_time_in_millis"].(float64); ok {
+		info.StartTime = time.UnixMilli(int64(ms))
+	}
+	if ms, ok := s["end_time_in_millis"].(float64); ok && ms > 0 {
+		info.EndTime = time.UnixMilli(int64(ms))
+	}
+
+	if shards, ok := s["shards"].(map[string]interface{}); ok {
+		total, _ := shards["total"].(float64)
+		successful, _ := shards["successful"].(float64)
+		failed, _ := shards["failed"].(float64)
+		info.ShardsTotal = int(total)
+		info.ShardsSuccessful = int(successful)
+		info.ShardsFailed = int(failed)
+	}
+
+	return info
+}
+
+// toStrings converts a decoded JSON array into a slice of strings
+func toStrings(v interface{}) []string {
+	var s []string
+	list, _ := v.([]interface{})
+	for _, element := range list {
+		if str, ok := element.(string); ok {
+			s = append(s, str)
+		}
+	}
+	return s
+}
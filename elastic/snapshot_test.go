@@ -0,0 +1,93 @@
+package elastic
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestSelectExpiredSnapshots(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	snapshot := func(name, state string, age time.Duration) SnapshotInfo {
+		return SnapshotInfo{Snapshot: name, State: state, StartTime: now.Add(-age)}
+	}
+
+	snapshots := []SnapshotInfo{
+		snapshot("s1", SnapshotSuccess, 1*day),
+		snapshot("s2", SnapshotSuccess, 2*day),
+		snapshot("s3", SnapshotSuccess, 3*day),
+		snapshot("s10", SnapshotSuccess, 10*day),
+		snapshot("failed", SnapshotFailed, 1*day),
+		snapshot("partial", SnapshotPartial, 20*day),
+		snapshot("running", SnapshotInProgress, 30*day),
+		snapshot("unknown", "INCOMPATIBLE", 30*day),
+	}
+
+	tests := []struct {
+		name      string
+		retention SnapshotRetention
+		want      []string
+	}{
+		{"no rule", SnapshotRetention{}, []string{"failed"}},
+		{"max age", SnapshotRetention{MaxAge: 5 * day}, []string{"failed", "s10"}},
+		{"max count", SnapshotRetention{MaxCount: 2}, []string{"failed", "s10", "s3"}},
+		{"min count", SnapshotRetention{MaxAge: 12 * time.Hour, MinCount: 2}, []string{"failed", "s10", "s3"}},
+		{"partial", SnapshotRetention{DeletePartial: true}, []string{"failed", "partial"}},
+	}
+
+	for _, test := range tests {
+		got := selectExpiredSnapshots(snapshots, test.retention, now)
+		sort.Strings(got)
+		sort.Strings(test.want)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestCreateSnapshotState(t *testing.T) {
+	states := map[string]bool{SnapshotSuccess: false, SnapshotPartial: true, SnapshotFailed: true}
+
+	for state, wantErr := range states {
+		useTransport(t, &fakeTransport{handler: func(req *http.Request) (*http.Response, error) {
+			return jsonResponse(http.StatusOK, `{"snapshot":{"snapshot":"s1","state":"`+state+`","shards":{"total":2,"successful":1,"failed":1}}}`), nil
+		}})
+
+		info, err := CreateSnapshot("backups", "s1", nil, true, 5)
+		if (err != nil) != wantErr {
+			t.Errorf("%s: got %v", state, err)
+		}
+		if info.State != state {
+			t.Errorf("%s: got info %+v", state, info)
+		}
+	}
+}
+
+func TestParseSnapshotInfo(t *testing.T) {
+	var s map[string]interface{}
+	err := json.Unmarshal([]byte(`{"snapshot":"s1","state":"PARTIAL","indices":["posts",1,"users"],"data_streams":[],
+		"start_time_in_millis":1717200000000,"end_time_in_millis":0,"shards":{"total":3,"successful":2,"failed":1}}`), &s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info := parseSnapshotInfo(s)
+	if info.Snapshot != "s1" || info.State != SnapshotPartial || info.ShardsTotal != 3 || info.ShardsSuccessful != 2 || info.ShardsFailed != 1 {
+		t.Errorf("got %+v", info)
+	}
+	if !info.StartTime.Equal(time.UnixMilli(1717200000000)) || !info.EndTime.IsZero() {
+		t.Errorf("got times %s, %s", info.StartTime, info.EndTime)
+	}
+	// non string elements are skipped
+	if !reflect.DeepEqual(info.Indices, []string{"posts", "users"}) || info.DataStreams != nil {
+		t.Errorf("got indices %v, data streams %v", info.Indices, info.DataStreams)
+	}
+
+	if toStrings("posts") != nil || toStrings(nil) != nil {
+		t.Error("toStrings of a non array")
+	}
+}
@@ -0,0 +1,172 @@
+package elastic
+
+import (
+	"fmt"
+)
+
+// Suggester is a named suggestion request
+// exactly one of Term, Phrase or Completion has to be set
+// Text is used by term and phrase suggesters, Prefix by the completion suggester
+type Suggester struct {
+	Text       string
+	Prefix     string
+	Term       *TermSuggester
+	Phrase     *PhraseSuggester
+	Completion *CompletionSuggester
+}
+
+// TermSuggester suggests corrections per term
+type TermSuggester struct {
+	Field       string `json:"field"`
+	Size        int    `json:"size,omitempty"`
+	SuggestMode string `json:"suggest_mode,omitempty"` // missing, popular or always
+	Sort        string `json:"sort,omitempty"`         // score or frequency
+	MinWordLen  int    `json:"min_word_length,omitempty"`
+	PrefixLen   *int   `json:"prefix_length,omitempty"`
+}
+
+// PhraseSuggester suggests corrections for a whole phrase ("did you mean")
+// Collate checks each suggestion against a query template, "{{suggestion}}" is replaced by the suggestion
+type PhraseSuggester struct {
+	Field      string                 `json:"field"`
+	Size       int                    `json:"size,omitempty"`
+	GramSize   int                    `json:"gram_size,omitempty"`
+	MaxErrors  float64                `json:"max_errors,omitempty"`
+	Confidence *float64               `json:"confidence,omitempty"`
+	Highlight  *SuggestHighlight      `json:"highlight,omitempty"`
+	Collate    map[string]interface{} `json:"collate,omitempty"`
+}
+
+// SuggestHighlight wraps corrected terms of phrase suggestions
+type SuggestHighlight struct {
+	PreTag  string `json:"pre_tag"`
+	PostTag string `json:"post_tag"`
+}
+
+// CompletionSuggester suggests completions from a completion field (search as you type)
+type CompletionSuggester struct {
+	Field          string                 `json:"field"`
+	Size           int                    `json:"size,omitempty"`
+	SkipDuplicates bool                   `json:"skip_duplicates,omitempty"`
+	Fuzzy          map[string]interface{} `json:"fuzzy,omitempty"`
+	Contexts       map[string]interface{} `json:"contexts,omitempty"`
+}
+
+// Suggestion is the suggestion for one token (term) or for the whole input (phrase, completion)
+type Suggestion struct {
+	Text    string
+	Offset  int
+	Length  int
+	Options []SuggestOption
+}
+
+// SuggestOption is a candidate of a suggestion
+// Freq is set for term suggestions, Highlighted for phrase suggestions,
+// Id, Index and Source for completion suggestions
+type SuggestOption struct {
+	Text        string
+	Score       float64
+	Freq        int
+	Highlighted string
+	Id          string
+	Index       string
+	Source      map[string]interface{}
+}
+
+// body returns the suggester as expected in the "suggest" part of a query
+func (s Suggester) body() (map[string]interface{}, error) {
+	b := make(map[string]interface{})
+
+	count := 0
+	if s.Term != nil {
+		b["term"] = s.Term
+		count++
+	}
+	if s.Phrase != nil {
+		b["phrase"] = s.Phrase
+		count++
+	}
+	if s.Completion != nil {
+		b["completion"] = s.Completion
+		if s.Prefix != "" {
+			b["prefix"] = s.Prefix
+		}
+		count++
+	}
+	if count != 1 {
+		return b, fmt.Errorf("exactly one of term, phrase or completion is required")
+	}
+
+	if s.Text != "" {
+		b["text"] = s.Text
+	}
+
+	return b, nil
+}
+
+// DidYouMean returns the best option of a phrase suggestion
+// return an empty string when there is no correction
+func DidYouMean(result SearchResult, name string) string {
+	for _, suggestion := range result.Suggestions[name] {
+		if len(suggestion.Options) > 0 {
+			return suggestion.Options[0].Text
+		}
+	}
+	return ""
+}
+
+// parseSuggestions converts the "suggest" part of a search response
+func parseSuggestions(r map[string]interface{}) map[string][]Suggestion {
+	suggest, ok := r["suggest"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	suggestions := make(map[string][]Suggestion, len(suggest))
+	for name, entries := range suggest {
+		list, _ := entries.([]interface{})
+		for _, element := range list {
+			entry, ok := element.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			var s Suggestion
+			s.Text, _ = entry["text"].(string)
+			if offset, ok := entry["offset"].(float64); ok {
+				s.Offset = int(offset)
+			}
+			if length, ok := entry["length"].(float64); ok {
+				s.Length = int(length)
+			}
+
+			options, _ := entry["options"].([]interface{})
+			for _, o := range options {
+				option, ok := o.(map[string]interface{})
+				if !ok {
+					continue
+				}
+
+				var so SuggestOption
+				so.Text, _ = option["text"].(string)
+				so.Highlighted, _ = option["highlighted"].(string)
+				so.Id, _ = option["_id"].(string)
+				so.Index, _ = option["_index"].(string)
+				so.Source, _ = option["_source"].(map[string]interface{})
+				if score, ok := option["score"].(float64); ok {
+					so.Score = score
+				} else if score, ok := option["_score"].(float64); ok {
+					so.Score = score
+				}
+				if freq, ok := option["freq"].(float64); ok {
+					so.Freq = int(freq)
+				}
+				s.Options = append(s.Options, so)
+			}
+
+			suggestions[name] = append(suggestions[name], s)
+		}
+	}
+
+	return suggestions
+}
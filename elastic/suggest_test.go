@@ -0,0 +1,74 @@
+package elastic
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSuggesterBody(t *testing.T) {
+	tests := []struct {
+		name      string
+		suggester Suggester
+		want      string
+		wantErr   bool
+	}{
+		{"term", Suggester{Text: "helo", Term: &TermSuggester{Field: "title"}}, `{"term":{"field":"title"},"text":"helo"}`, false},
+		{"completion", Suggester{Prefix: "go", Completion: &CompletionSuggester{Field: "suggest", Size: 5}}, `{"completion":{"field":"suggest","size":5},"prefix":"go"}`, false},
+		{"none", Suggester{Text: "helo"}, "", true},
+		{"several", Suggester{Term: &TermSuggester{Field: "a"}, Phrase: &PhraseSuggester{Field: "a"}}, "", true},
+	}
+
+	for _, test := range tests {
+		body, err := test.suggester.body()
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: no error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		b, _ := json.Marshal(body)
+		if string(b) != test.want {
+			t.Errorf("%s: got %s, want %s", test.name, b, test.want)
+		}
+	}
+}
+
+func TestParseSuggestions(t *testing.T) {
+	var r map[string]interface{}
+	err := json.Unmarshal([]byte(`{"suggest":{
+		"spelling":[{"text":"helo wrld","offset":0,"length":9,"options":[{"text":"hello world","highlighted":"<em>hello world</em>","score":0.8}]}],
+		"words":[{"text":"helo","offset":0,"length":4,"options":[{"text":"hello","score":0.75,"freq":12}]},{"text":"wrld","offset":5,"length":4,"options":[]}],
+		"titles":[{"text":"go","offset":0,"length":2,"options":[{"text":"golang","_index":"posts","_id":"1","_score":2,"_source":{"title":"golang"}}]}]
+	}}`), &r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	suggestions := parseSuggestions(r)
+	if len(suggestions) != 3 {
+		t.Fatalf("got %v", suggestions)
+	}
+
+	if s := suggestions["words"]; len(s) != 2 || s[1].Offset != 5 || s[0].Options[0].Freq != 12 {
+		t.Errorf("term suggestions: got %+v", s)
+	}
+	if o := suggestions["titles"][0].Options[0]; o.Id != "1" || o.Index != "posts" || o.Score != 2 || o.Source["title"] != "golang" {
+		t.Errorf("completion option: got %+v", o)
+	}
+
+	result := SearchResult{Suggestions: suggestions}
+	if got := DidYouMean(result, "spelling"); got != "hello world" {
+		t.Errorf("DidYouMean: got %q", got)
+	}
+	if got := DidYouMean(result, "missing"); got != "" {
+		t.Errorf("DidYouMean of a missing suggestion: got %q", got)
+	}
+
+	if parseSuggestions(map[string]interface{}{}) != nil {
+		t.Error("suggestions without suggest part")
+	}
+}
@@ -0,0 +1,187 @@
+package elastic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// search templates are mustache scripts stored in the cluster state
+// params are any value serialized as a JSON object (a struct with json tags or a map)
+// so query shapes are versioned centrally and executed by id
+
+// PutSearchTemplate stores (creates or replaces) a mustache search template
+// source is the template, e.g. `{"query":{"match":{"title":"{{text}}"}},"size":"{{size}}{{^size}}10{{/size}}"}`
+func PutSearchTemplate(id, source string, timeOut int) error {
+
+	// CHECKS
+	if id == "" || source == "" {
+		return fmt.Errorf("template id and source are required")
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"script": map[string]interface{}{
+			"lang":   "mustache",
+			"source": source,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Set up the request object.
+	req := esapi.PutScriptRequest{
+		ScriptID: id,
+		Body:     bytes.NewReader(body),
+	}
+
+	_, err = doRequest(req, "PutSearchTemplate", timeOut)
+	return err
+}
+
+// GetSearchTemplate returns the source of a stored search template
+func GetSearchTemplate(id string, timeOut int) (string, error) {
+
+	// Set up a context with a timeout.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOut)*time.Second)
+	defer cancel()
+
+	// Set up the request object.
+	req := esapi.GetScriptRequest{
+		ScriptID: id,
+	}
+
+	// Perform the request with the client.
+	res, err := req.Do(ctx, esTransport)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", fmt.Errorf("GetSearchTemplate - request timed out")
+		}
+		return "", fmt.Errorf("es error getting response: %w", err)
+	}
+
+	// Securely close Body
+	if res.Body != nil {
+		defer res.Body.Close()
+	}
+
+	// a missing script is answered with a 404
+	if res.StatusCode == http.StatusNotFound {
+		return "", fmt.Errorf("no search template with id '%s'", id)
+	}
+
+	//  deserialize response and possible errors
+	r, err := getResponseMap(res)
+	if err != nil {
+		return "", err
+	}
+
+	script, _ := r["script"].(map[string]interface{})
+	source, ok := script["source"].(string)
+	if !ok {
+		return "", fmt.Errorf("could not get template source from response")
+	}
+
+	return source, nil
+}
+
+// DeleteSearchTemplate deletes a stored search template
+func DeleteSearchTemplate(id string, timeOut int) error {
+
+	// Set up the request object.
+	req := esapi.DeleteScriptRequest{
+		ScriptID: id,
+	}
+
+	_, err := doRequest(req, "DeleteSearchTemplate", timeOut)
+	return err
+}
+
+// RenderSearchTemplate returns the query produced by a stored template with the given params
+// useful to check a template without executing it
+func RenderSearchTemplate(id string, params interface{}, timeOut int) (map[string]interface{}, error) {
+
+	body, err := templateBody("", params)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set up the request object.
+	req := esapi.RenderSearchTemplateRequest{
+		TemplateID: id,
+		Body:       bytes.NewReader(body),
+	}
+
+	r, err := doRequest(req, "RenderSearchTemplate", timeOut)
+	if err != nil {
+		return nil, err
+	}
+
+	output, ok := r["template_output"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("could not get template output from response")
+	}
+
+	return output, nil
+}
+
+// ExecuteSearchTemplate runs a stored search template with the given params
+// totals are exact, as with Search
+func ExecuteSearchTemplate(indices []string, id string, params interface{}, timeOut int) (SearchResult, error) {
+
+	var result SearchResult
+
+	// CHECKS
+	if err := checkSearchIndices(indices, false); err != nil {
+		return result, err
+	}
+
+	body, err := templateBody(id, params)
+	if err != nil {
+		return result, err
+	}
+
+	// Set up the request object.
+	// an integer total tracks all hits, unless the template sets track_total_hits
+	totalAsInt := true
+	req := esapi.SearchTemplateRequest{
+		Index:              indices,
+		Body:               bytes.NewReader(body),
+		RestTotalHitsAsInt: &totalAsInt,
+	}
+
+	r, err := doRequest(req, "ExecuteSearchTemplate", timeOut)
+	if err != nil {
+		return result, err
+	}
+
+	return parseSearchResult(r), nil
+}
+
+// templateBody builds the body of render and execute requests
+// params must serialize to a JSON object
+func templateBody(id string, params interface{}) ([]byte, error) {
+	b := make(map[string]interface{})
+	if id != "" {
+		b["id"] = id
+	}
+
+	if params != nil {
+		p, err := json.Marshal(params)
+		if err != nil {
+			return nil, err
+		}
+		var m map[string]interface{}
+		if err := json.Unmarshal(p, &m); err != nil {
+			return nil, fmt.Errorf("template params must be a JSON object: %s", err)
+		}
+		b["params"] = m
+	}
+
+	return json.Marshal(b)
+}
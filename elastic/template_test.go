@@ -0,0 +1,87 @@
+package elastic
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestGetSearchTemplateMissing(t *testing.T) {
+	useTransport(t, &fakeTransport{handler: func(req *http.Request) (*http.Response, error) {
+		return jsonResponse(http.StatusNotFound, `{"_id":"posts","found":false}`), nil
+	}})
+
+	_, err := GetSearchTemplate("posts", 5)
+	if err == nil || err.Error() != "no search template with id 'posts'" {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestExecuteSearchTemplateTotal(t *testing.T) {
+	transport := &fakeTransport{handler: func(req *http.Request) (*http.Response, error) {
+		return jsonResponse(http.StatusOK, `{"hits":{"total":25000,"hits":[{"_id":"1","_source":{"a":1}}]}}`), nil
+	}}
+	useTransport(t, transport)
+
+	result, err := ExecuteSearchTemplate([]string{"posts-*"}, "posts", map[string]interface{}{"text": "go"}, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Total != 25000 || len(result.Hits) != 1 {
+		t.Errorf("got total %d, %d hits", result.Total, len(result.Hits))
+	}
+	if q := transport.requests[0].URL.RawQuery; !strings.Contains(q, "rest_total_hits_as_int=true") {
+		t.Errorf("got params %s", q)
+	}
+}
+
+func TestTemplateBody(t *testing.T) {
+	type params struct {
+		Text string `json:"text"`
+		Size int    `json:"size,omitempty"`
+	}
+
+	tests := []struct {
+		name    string
+		id      string
+		params  interface{}
+		want    string
+		wantErr bool
+	}{
+		{"no params", "posts", nil, `{"id":"posts"}`, false},
+		{"struct", "posts", params{Text: "go"}, `{"id":"posts","params":{"text":"go"}}`, false},
+		{"map", "", map[string]interface{}{"size": 5}, `{"params":{"size":5}}`, false},
+		{"not an object", "posts", []string{"go"}, "", true},
+	}
+
+	for _, test := range tests {
+		body, err := templateBody(test.id, test.params)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: no error", test.name)
+			}
+			continue
+		}
+		if err != nil || string(body) != test.want {
+			t.Errorf("%s: got %s, %v, want %s", test.name, body, err, test.want)
+		}
+	}
+}
+
+func TestRenderSearchTemplate(t *testing.T) {
+	transport := &fakeTransport{handler: func(req *http.Request) (*http.Response, error) {
+		return jsonResponse(http.StatusOK, `{"template_output":{"query":{"match":{"title":"go"}}}}`), nil
+	}}
+	useTransport(t, transport)
+
+	output, err := RenderSearchTemplate("posts", map[string]string{"text": "go"}, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output["query"] == nil {
+		t.Errorf("got %v", output)
+	}
+	if n := transport.count(http.MethodPost, "/_render/template/posts"); n != 1 {
+		t.Errorf("%d render requests, want 1", n)
+	}
+}
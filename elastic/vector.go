@@ -0,0 +1,242 @@
+package elastic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// vector similarity functions supported by dense_vector fields
+const (
+	SimilarityCosine       = "cosine"
+	SimilarityDotProduct   = "dot_product"
+	SimilarityL2Norm       = "l2_norm"
+	SimilarityMaxInnerProd = "max_inner_product"
+)
+
+// KnnQuery is an approximate kNN clause on a dense_vector field
+// Filter is a regular query restricting the documents that can match
+// Similarity is the minimum similarity for a document to be a match (optional)
+// Boost is ignored with Rrf, the knn retriever does not accept it
+type KnnQuery struct {
+	Field         string                 `json:"field"`
+	QueryVector   []float32              `json:"query_vector"`
+	K             int                    `json:"k"`
+	NumCandidates int                    `json:"num_candidates"`
+	Filter        map[string]interface{} `json:"filter,omitempty"`
+	Similarity    *float64               `json:"similarity,omitempty"`
+	Boost         float64                `json:"boost,omitempty"`
+}
+
+// Rrf configures Reciprocal Rank Fusion for hybrid (lexical + kNN) ranking
+// zero values keep elastic defaults
+type Rrf struct {
+	RankWindowSize int
+	RankConstant   int
+}
+
+// KnnSearchRequest describes a kNN search, optionally combined with a lexical query
+// without Rrf, kNN and query scores are summed (use Boost to weight them)
+// with Rrf, both result sets are merged by rank (requires elastic >= 8.16 and a suitable license)
+type KnnSearchRequest struct {
+	Knn    []KnnQuery
+	Query  map[string]interface{}
+	Rrf    *Rrf
+	Size   int
+	From   int
+	Source []string
+}
+
+// KnnSearch runs a kNN (or hybrid) search
+// return hits with scores, total, err
+func KnnSearch(indices []string, request KnnSearchRequest, timeOut int) ([]Hit, int, error) {
+
+	var hits []Hit
+	var total int
+
+	// CHECKS
+	if len(request.Knn) == 0 {
+		return hits, total, fmt.Errorf("at least one knn clause is required")
+	}
+	for _, knn := range request.Knn {
+		if knn.Field == "" || len(knn.QueryVector) == 0 {
+			return hits, total, fmt.Errorf("knn clause needs a field and a query vector")
+		}
+		if knn.K <= 0 || knn.NumCandidates < knn.K {
+			return hits, total, fmt.Errorf("knn clause on %s: k must be > 0 and num_candidates >= k", knn.Field)
+		}
+	}
+
+	if err := checkSearchIndices(indices, false); err != nil {
+		return hits, total, err
+	}
+
+	query := buildKnnQuery(request)
+
+	body, err := json.Marshal(query)
+	if err != nil {
+		return hits, total, err
+	}
+
+	// Set up a context with a timeout.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOut)*time.Second)
+	defer cancel()
+
+	// Set up the request object.
+	req := esapi.SearchRequest{
+		Index:          indices,
+		Body:           bytes.NewReader(body),
+		TrackTotalHits: true,
+	}
+
+	// Perform the request with the client.
+	res, err := req.Do(ctx, esTransport)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return hits, total, fmt.Errorf("KnnSearch - request timed out")
+		}
+		return hits, total, fmt.Errorf("es error getting response: %w", err)
+	}
+
+	// Securely close Body
+	if res.Body != nil {
+		defer res.Body.Close()
+	}
+
+	//  deserialize response and possible errors
+	r, err := getResponseMap(res)
+	if err != nil {
+		return hits, total, err
+	}
+
+	hits, total = parseHits(r)
+
+	return hits, total, nil
+}
+
+// buildKnnQuery builds the search body
+// hybrid ranking with RRF is expressed with retrievers
+func buildKnnQuery(request KnnSearchRequest) map[string]interface{} {
+	query := make(map[string]interface{})
+
+	if request.Size > 0 {
+		query["size"] = request.Size
+	}
+	if request.From > 0 {
+		query["from"] = request.From
+	}
+	if len(request.Source) > 0 {
+		query["_source"] = request.Source
+	}
+
+	if request.Rrf == nil {
+		if len(request.Knn) == 1 {
+			query["knn"] = request.Knn[0]
+		} else {
+			query["knn"] = request.Knn
+		}
+		if request.Query != nil {
+			query["query"] = request.Query
+		}
+		return query
+	}
+
+	var retrievers []map[string]interface{}
+	if request.Query != nil {
+		retrievers = append(retrievers, map[string]interface{}{
+			"standard": map[string]interface{}{"query": request.Query},
+		})
+	}
+	for _, knn := range request.Knn {
+		knn.Boost = 0
+		retrievers = append(retrievers, map[string]interface{}{"knn": knn})
+	}
+
+	rrf := map[string]interface{}{"retrievers": retrievers}
+	if request.Rrf.RankWindowSize > 0 {
+		rrf["rank_window_size"] = request.Rrf.RankWindowSize
+	}
+	if request.Rrf.RankConstant > 0 {
+		rrf["rank_constant"] = request.Rrf.RankConstant
+	}
+	query["retriever"] = map[string]interface{}{"rrf": rrf}
+
+	return query
+}
+
+// DenseVectorMapping returns the mapping of an indexed dense_vector field
+// similarity is one of the Similarity* constants
+func DenseVectorMapping(dims int, similarity string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":       "dense_vector",
+		"dims":       dims,
+		"index":      true,
+		"similarity": similarity,
+	}
+}
+
+// PutDenseVectorMapping adds a dense_vector field to an existing index mapping
+// an existing field with different dims or similarity is rejected by elastic
+func PutDenseVectorMapping(index, field string, dims int, similarity string, timeOut int) error {
+
+	// CHECKS
+	if dims <= 0 {
+		return fmt.Errorf("dims must be > 0")
+	}
+
+	exists, err := IndexExists(index)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return fmt.Errorf("no index with name '%s'", index)
+	}
+
+	mapping := map[string]interface{}{
+		"properties": map[string]interface{}{
+			field: DenseVectorMapping(dims, similarity),
+		},
+	}
+
+	body, err := json.Marshal(mapping)
+	if err != nil {
+		return err
+	}
+
+	// Set up the request object.
+	req := esapi.IndicesPutMappingRequest{
+		Index: []string{index},
+		Body:  bytes.NewReader(body),
+	}
+
+	// Set up a context with a timeout.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeOut)*time.Second)
+	defer cancel()
+
+	// Perform the request with the client.
+	res, err := req.Do(ctx, esTransport)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("PutDenseVectorMapping - request timed out")
+		}
+		return fmt.Errorf("es error getting response: %w", err)
+	}
+
+	// Securely close Body
+	if res.Body != nil {
+		defer res.Body.Close()
+	}
+
+	//  deserialize response and possible errors
+	_, err = getResponseMap(res)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,38 @@
+package elastic
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestBuildKnnQueryRrf(t *testing.T) {
+	knn := KnnQuery{Field: "vector", QueryVector: []float32{1, 0}, K: 5, NumCandidates: 50, Boost: 2}
+	request := KnnSearchRequest{
+		Knn:   []KnnQuery{knn},
+		Query: map[string]interface{}{"match": map[string]interface{}{"title": "go"}},
+		Rrf:   &Rrf{RankWindowSize: 100},
+	}
+
+	body, err := json.Marshal(buildKnnQuery(request))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(body)
+	if strings.Contains(got, "boost") {
+		t.Errorf("boost in the knn retriever: %s", got)
+	}
+	if !strings.Contains(got, `"rank_window_size":100`) || strings.Contains(got, "rank_constant") {
+		t.Errorf("rrf options: %s", got)
+	}
+
+	// without rrf, the boost weights the knn scores
+	request.Rrf = nil
+	body, err = json.Marshal(buildKnnQuery(request))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(body), `"boost":2`) {
+		t.Errorf("no boost in the knn clause: %s", body)
+	}
+}